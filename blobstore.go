@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAttachmentsPath = "attachments"
+	DefaultAttachmentsTTL  = 72 * time.Hour
+	// Captured attachments are uploaded with the report, so they must fit in reportUploadLimit
+	DefaultAttachmentsMaxSize  = 8 * 1024 * 1024
+	DefaultAttachmentsMaxCount = 4
+
+	// AttachmentsCaptureWait is how long the report of the deleted message waits for attachments still being downloaded
+	AttachmentsCaptureWait = 5 * time.Second
+)
+
+var ErrBlobTooLarge = errors.New("blob exceeds the size limit")
+
+// BlobStore keeps files on the disk grouped by the owner, e.g. the message ID.
+// Files older than TTL are removed by Prune.
+type BlobStore struct {
+	dir string
+	ttl time.Duration
+}
+
+type Blob struct {
+	Name string
+	Path string
+}
+
+func NewBlobStore(dir string, ttl time.Duration) (*BlobStore, error) {
+	if dir == "" {
+		dir = DefaultAttachmentsPath
+	}
+	if ttl <= 0 {
+		ttl = DefaultAttachmentsTTL
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory %s: %w", dir, err)
+	}
+
+	return &BlobStore{dir: dir, ttl: ttl}, nil
+}
+
+var blobNameRegex = regexp.MustCompile(`[^\w.-]+`)
+
+// Put writes at most maxSize bytes from the reader. When the reader has more
+// data, the file is removed and ErrBlobTooLarge is returned.
+func (s *BlobStore) Put(owner, name string, r io.Reader, maxSize int64) error {
+	ownerDir := filepath.Join(s.dir, blobNameRegex.ReplaceAllString(owner, "_"))
+	if err := os.MkdirAll(ownerDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", owner, err)
+	}
+
+	path := filepath.Join(ownerDir, blobNameRegex.ReplaceAllString(name, "_"))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	written, err := io.Copy(file, io.LimitReader(r, maxSize+1))
+	if err == nil && written > maxSize {
+		err = ErrBlobTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// List returns all blobs stored for the given owner
+func (s *BlobStore) List(owner string) ([]Blob, error) {
+	ownerDir := filepath.Join(s.dir, blobNameRegex.ReplaceAllString(owner, "_"))
+
+	entries, err := os.ReadDir(ownerDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Blob{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs for %s: %w", owner, err)
+	}
+
+	blobs := []Blob{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		blobs = append(blobs, Blob{
+			Name: entry.Name(),
+			Path: filepath.Join(ownerDir, entry.Name()),
+		})
+	}
+
+	return blobs, nil
+}
+
+// Prune removes owners whose files are older than TTL
+func (s *BlobStore) Prune() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blob store directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if time.Since(info.ModTime()) < s.ttl {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(s.dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", entry.Name(), err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// AttachmentCaptures tracks messages with attachments being downloaded, so the message deleted
+// right after it was posted is reported with its attachments
+type AttachmentCaptures struct {
+	mut      sync.Mutex
+	captures map[string]chan struct{}
+}
+
+func NewAttachmentCaptures() *AttachmentCaptures {
+	return &AttachmentCaptures{
+		captures: map[string]chan struct{}{},
+	}
+}
+
+// Start marks the capture of the message attachments as in progress, the returned function marks it as done
+func (c *AttachmentCaptures) Start(messageID string) func() {
+	done := make(chan struct{})
+
+	c.mut.Lock()
+	c.captures[messageID] = done
+	c.mut.Unlock()
+
+	return func() {
+		c.mut.Lock()
+		if c.captures[messageID] == done {
+			delete(c.captures, messageID)
+		}
+		c.mut.Unlock()
+
+		close(done)
+	}
+}
+
+// Wait blocks until the capture of the message attachments is done or the timeout passes.
+// It returns false when the capture is still in progress.
+func (c *AttachmentCaptures) Wait(messageID string, timeout time.Duration) bool {
+	c.mut.Lock()
+	done, found := c.captures[messageID]
+	c.mut.Unlock()
+
+	if !found {
+		return true
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
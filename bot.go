@@ -28,29 +28,103 @@ type ServerUser struct {
 	validUntil time.Time
 }
 
+type cachedUserKey struct {
+	GuildID string
+	UserID  UserID
+}
+
 type DiscordBot struct {
 	m sync.RWMutex
 
-	Config Config
+	config       atomic.Pointer[Config]
+	linkResolver atomic.Pointer[LinkResolver]
 
 	guildsIDs     []string
 	applicationId string
 
-	cachedUsers      map[UserID]ServerUser
+	cachedUsers      map[cachedUserKey]ServerUser
 	cachedRoles      map[RoleID]RoleName
 	cachedRolesReady bool
 
-	wipeInProgress atomic.Bool
-	wipedMessages  CachedList[string]
+	wipedMessages   CachedList[string]
+	pendingWipes    *PendingWipes
+	wipeJobs        *WipeJobs
+	wipeCheckpoints *WipeCheckpoints
+	restoreWebhooks CachedList[string]
+	messageWindows  *MessageWindows
+	invites         *InviteCache
+
+	messages           MessageStore
+	attachments        *BlobStore
+	attachmentCaptures *AttachmentCaptures
 }
 
-func NewDiscordBot(config Config) *DiscordBot {
-	return &DiscordBot{
-		Config:      config,
-		cachedUsers: map[UserID]ServerUser{},
+func NewDiscordBot(config *Config, messages MessageStore, attachments *BlobStore, wipeCheckpoints *WipeCheckpoints) (*DiscordBot, error) {
+	bot := &DiscordBot{
+		cachedUsers: map[cachedUserKey]ServerUser{},
 		guildsIDs:   []string{},
+		messages:    messages,
+		attachments: attachments,
+
+		attachmentCaptures: NewAttachmentCaptures(),
+
+		wipedMessages:   NewCacheList[string](),
+		pendingWipes:    NewPendingWipes(),
+		wipeJobs:        NewWipeJobs(),
+		wipeCheckpoints: wipeCheckpoints,
+		restoreWebhooks: NewCacheList[string](),
+		messageWindows:  NewMessageWindows(),
+		invites:         NewInviteCache(),
+	}
+	if err := bot.UpdateConfig(config); err != nil {
+		return nil, err
+	}
+
+	return bot, nil
+}
+
+// Config returns the current config. It is swapped when the config is reloaded,
+// so handlers should get it for every event and never modify it.
+func (b *DiscordBot) Config() *Config {
+	return b.config.Load()
+}
+
+// UpdateConfig swaps the config and the link resolver created for it. When the resolver cannot be created,
+// the previous config and resolver are kept.
+func (b *DiscordBot) UpdateConfig(config *Config) error {
+	// The resolver keeps connections open between messages, so it is created only when the config changes
+	resolver, err := NewLinkResolver(config.LinkResolver)
+	if err != nil {
+		return fmt.Errorf("failed to create link resolver: %w", err)
+	}
 
-		wipedMessages: NewCacheList[string](),
+	b.config.Store(config)
+	if previous := b.linkResolver.Swap(resolver); previous != nil {
+		previous.client.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// LinkResolver returns the resolver for the current config
+func (b *DiscordBot) LinkResolver() *LinkResolver {
+	return b.linkResolver.Load()
+}
+
+// ReplaceChannel updates references to the channel in the running config. The swap is retried
+// when the config is reloaded at the same time, so none of the changes is lost.
+func (b *DiscordBot) ReplaceChannel(oldID, newID string) error {
+	for {
+		config := b.config.Load()
+
+		replaced, err := config.ReplaceChannel(oldID, newID)
+		if err != nil {
+			return err
+		}
+
+		if b.config.CompareAndSwap(config, replaced) {
+			return nil
+		}
 	}
 }
 
@@ -114,17 +188,20 @@ func (b *DiscordBot) UpdateGuildsIDs(guilds []string) {
 	b.guildsIDs = guilds
 }
 
-func (b *DiscordBot) AddCachedUser(id UserID, details ServerUser) {
+func (b *DiscordBot) AddCachedUser(guildID string, id UserID, details ServerUser) {
 	b.m.Lock()
 	defer b.m.Unlock()
 
 	details.validUntil = time.Now().Add(cacheValid)
 
-	b.cachedUsers[id] = details
+	b.cachedUsers[cachedUserKey{GuildID: guildID, UserID: id}] = details
 }
 
-func (b *DiscordBot) CachedUser(id UserID) *ServerUser {
-	details, found := b.cachedUsers[id]
+func (b *DiscordBot) CachedUser(guildID string, id UserID) *ServerUser {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	details, found := b.cachedUsers[cachedUserKey{GuildID: guildID, UserID: id}]
 	if !found {
 		return nil
 	}
@@ -138,9 +215,19 @@ func (b *DiscordBot) CachedUser(id UserID) *ServerUser {
 }
 
 func (b *DiscordBot) UpdateApplicationId(id string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
 	b.applicationId = id
 }
 
+func (b *DiscordBot) ApplicationID() string {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	return b.applicationId
+}
+
 func (b *DiscordBot) CachedRole(id RoleID) RoleName {
 	b.m.RLock()
 	defer b.m.RUnlock()
@@ -177,6 +264,77 @@ func (b *DiscordBot) ClearCachedWipedMessageIDs(ctx context.Context, logger *zap
 	}
 }
 
-func (b *DiscordBot) IsModeratedChannel(channelID string) bool {
-	return slices.Contains(b.Config.ModeratedChannels, channelID)
+func (b *DiscordBot) PruneMessageStore(ctx context.Context, logger *zap.Logger) {
+	t := time.NewTicker(cacheValid)
+
+	retention := b.Config().MessageStore.Retention
+	if retention <= 0 {
+		retention = DefaultMessageStoreRetention
+	}
+
+	for {
+		removed, err := b.messages.Prune(time.Now().Add(-retention))
+		if err != nil {
+			logger.Error("failed to prune message store", zap.Error(err))
+		} else {
+			logger.Sugar().Infof("Pruned %d messages from the message store", removed)
+		}
+
+		select {
+		case <-t.C:
+			continue
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *DiscordBot) PruneAttachments(ctx context.Context, logger *zap.Logger) {
+	if b.attachments == nil {
+		return
+	}
+
+	t := time.NewTicker(cacheValid)
+
+	for {
+		removed, err := b.attachments.Prune()
+		if err != nil {
+			logger.Error("failed to prune attachments", zap.Error(err))
+		} else {
+			logger.Sugar().Infof("Pruned attachments of %d messages", removed)
+		}
+
+		select {
+		case <-t.C:
+			continue
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// StoreMessage saves message from the moderated channel in the message store
+func (b *DiscordBot) StoreMessage(logger *zap.Logger, message *discordgo.Message) {
+	if message == nil || !b.IsModeratedChannel(message.GuildID, message.ChannelID) {
+		return
+	}
+
+	if err := b.messages.Save(message); err != nil {
+		logger.Sugar().Warnf("failed to save message %s in the message store: %s", message.ID, err.Error())
+	}
+}
+
+// RecordAuthor saves the first message of the author in the guild, it is used by the first message score signal
+func (b *DiscordBot) RecordAuthor(logger *zap.Logger, message *discordgo.Message) {
+	if message == nil || message.Author == nil || message.Author.Bot || message.GuildID == "" {
+		return
+	}
+
+	if _, err := b.messages.RecordAuthor(message.GuildID, message.Author.ID, message.ID); err != nil {
+		logger.Sugar().Warnf("failed to record author of message %s in the message store: %s", message.ID, err.Error())
+	}
+}
+
+func (b *DiscordBot) IsModeratedChannel(guildID, channelID string) bool {
+	return slices.Contains(b.Config().ForGuild(guildID).ModeratedChannels, channelID)
 }
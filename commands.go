@@ -13,105 +13,289 @@ import (
 
 const (
 	DefaultRequestTimeout = 10 * time.Second
+
+	WipeTimeout = 30 * time.Minute
+	// Discord allows to fetch and bulk delete up to 100 messages at once
+	WipePageSize = 100
+	// Bulk delete rejects messages older than 14 days, keep some margin for clocks drift
+	BulkDeleteMaxAge = 14*24*time.Hour - time.Hour
+)
+
+const (
+	DefaultWipeCommandName = "wipe"
+
+	WipeSubcommandRun    = "run"
+	WipeSubcommandCancel = "cancel"
+	WipeSubcommandStatus = "status"
+	WipeSubcommandNuke   = "nuke"
+
+	WipeOptionJobID = "id"
 )
 
+// wipeCommandName returns the slash command name. The prefix from the old `$wipe` command is ignored.
+func wipeCommandName(config ConfigCommandWipe) string {
+	name := strings.TrimPrefix(config.Command, "$")
+	if name == "" {
+		return DefaultWipeCommandName
+	}
+
+	return name
+}
+
+func wipeApplicationCommand() ApplicationCommand {
+	return ApplicationCommand{
+		Definition: func(config ConfigGuild) *discordgo.ApplicationCommand {
+			if !config.Commands.Wipe.Enabled {
+				return nil
+			}
+
+			return &discordgo.ApplicationCommand{
+				Name:        wipeCommandName(config.Commands.Wipe),
+				Description: "Delete messages in the channel",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        WipeSubcommandRun,
+						Description: "Delete messages in the channel",
+						Options:     append(wipeFilterOptions(), wipeDryRunOption()),
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        WipeSubcommandCancel,
+						Description: "Cancel the running wipe job",
+						Options: []*discordgo.ApplicationCommandOption{
+							{
+								Type:        discordgo.ApplicationCommandOptionString,
+								Name:        WipeOptionJobID,
+								Description: "ID of the wipe job",
+								Required:    true,
+							},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        WipeSubcommandStatus,
+						Description: "Show running wipe jobs",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionSubCommand,
+						Name:        WipeSubcommandNuke,
+						Description: "Clone the channel and delete the original one with all the messages",
+					},
+				},
+			}
+		},
+		WhitelistedRoles: func(config ConfigGuild) []string {
+			return config.Commands.Wipe.WhitelistedRoles
+		},
+		ActiveChannels: func(config ConfigGuild) []string {
+			return config.Commands.Wipe.ActiveChannels
+		},
+		Handler: func(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, config ConfigGuild) {
+			commandWipe(logger, interaction, discord, bot)
+		},
+	}
+}
+
 func commandWipe(
 	logger *zap.Logger,
-	message *discordgo.MessageCreate,
+	interaction *discordgo.InteractionCreate,
 	discord *discordgo.Session,
 	bot *DiscordBot,
-	config ConfigCommandWipe,
-	reportChannel string,
 ) {
-	if !config.Enabled {
+	options := interaction.ApplicationCommandData().Options
+	if len(options) < 1 {
+		respondEphemeral(logger, discord, interaction, "Unknown subcommand.")
 		return
 	}
 
-	if !strings.HasPrefix(message.Content, config.Command) {
-		logger.Sugar().Debugf(
-			"Message is not wipe command",
-		)
+	subcommand := options[0]
+	switch subcommand.Name {
+	case WipeSubcommandRun:
+		commandWipeRun(logger, interaction, discord, bot, subcommand.Options)
+	case WipeSubcommandCancel:
+		jobID := ""
+		for _, option := range subcommand.Options {
+			if option.Name == WipeOptionJobID {
+				jobID = option.StringValue()
+			}
+		}
+		commandWipeCancel(logger, interaction, discord, bot, jobID)
+	case WipeSubcommandStatus:
+		commandWipeStatus(logger, interaction, discord, bot)
+	case WipeSubcommandNuke:
+		commandWipeNuke(logger, interaction, discord, bot)
+	default:
+		respondEphemeral(logger, discord, interaction, "Unknown subcommand.")
+	}
+}
+
+func commandWipeRun(
+	logger *zap.Logger,
+	interaction *discordgo.InteractionCreate,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	options []*discordgo.ApplicationCommandInteractionDataOption,
+) {
+	if bot.wipeJobs.ChannelBusy(interaction.ChannelID) {
+		respondEphemeral(logger, discord, interaction, "Wipe job is still running in this channel, wait until it is finished or cancel it before triggering next command.")
+
+		logger.Info("Wipe job is still running in the channel. Wait before it finishes")
+		return
+	}
 
+	filter, err := wipeFilterFromOptions(options)
+	if err != nil {
+		respondEphemeral(logger, discord, interaction, err.Error())
 		return
 	}
+	dryRun := wipeDryRunFromOptions(options)
 
-	if message.ChannelID == "" || !slices.Contains(config.ActiveChannels, message.ChannelID) {
-		logger.Sugar().Debugf(
-			"Channel(%s) has not enabled wipe command",
-			message.ChannelID,
-		)
+	deferEphemeral(logger, discord, interaction)
 
+	previewCtx, previewCancel := context.WithTimeout(context.Background(), WipeTimeout)
+	defer previewCancel()
+
+	preview, err := previewWipe(previewCtx, logger, discord, interaction.ChannelID, filter)
+	if err != nil {
+		logger.Error("Failed to preview wipe", zap.Error(err))
+		editResponse(logger, discord, interaction, fmt.Sprintf("Failed to count messages to delete: %s", err.Error()))
 		return
 	}
 
-	if message.Author == nil {
-		logger.Sugar().Debugf(
-			"Author is unknown, wipe command cannot be executed",
-		)
-
+	if preview.Count < 1 {
+		editResponse(logger, discord, interaction, "No messages match the filters.")
 		return
 	}
 
-	// Ignore messages from bot itself
-	if message.Author != nil && message.Author.ID == discord.State.User.ID {
+	embeds := []*discordgo.MessageEmbed{preview.Embed(interaction.ChannelID, filter, dryRun)}
+	if dryRun {
+		if _, err := discord.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{Embeds: &embeds}); err != nil {
+			logger.Error("failed to edit interaction response", zap.Error(err))
+		}
 		return
 	}
 
-	if bot.wipeInProgress.Load() {
-		msg := fmt.Sprintf("Wipe command is still in progress, wait until it is finished before triggering next command.")
-		discord.ChannelMessageSend(reportChannel, msg)
+	token, err := bot.pendingWipes.Add(&PendingWipe{
+		UserID:      interactionUser(interaction).ID,
+		GuildID:     interaction.GuildID,
+		ChannelID:   interaction.ChannelID,
+		Filter:      filter,
+		Preview:     preview,
+		interaction: interaction,
+	}, func(pending *PendingWipe) {
+		logger.Sugar().Infof("Wipe confirmation for channel %s expired", pending.ChannelID)
 
-		logger.Info("Wipe command is still running. Wait before it finishes")
+		content := "Wipe confirmation expired, nothing was deleted."
+		components := []discordgo.MessageComponent{}
+		if _, err := discord.InteractionResponseEdit(pending.interaction.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &components,
+		}); err != nil {
+			logger.Error("failed to edit interaction response", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logger.Error("Failed to create wipe confirmation", zap.Error(err))
+		editResponse(logger, discord, interaction, fmt.Sprintf("Failed to create wipe confirmation: %s", err.Error()))
 		return
 	}
 
-	bot.wipeInProgress.Store(true)
-	defer bot.wipeInProgress.Store(false)
+	content := fmt.Sprintf("Confirm the wipe within %s.", WipeConfirmationTimeout)
+	components := wipeConfirmationComponents(token)
+	if _, err := discord.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Embeds:     &embeds,
+		Components: &components,
+	}); err != nil {
+		logger.Error("failed to edit interaction response", zap.Error(err))
+	}
+}
+
+// confirmWipe starts the wipe job after the moderator confirmed the preview
+func confirmWipe(
+	logger *zap.Logger,
+	interaction *discordgo.InteractionCreate,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	pending *PendingWipe,
+) {
+	if pending.Nuke {
+		confirmNuke(logger, interaction, discord, bot, pending)
+		return
+	}
 
-	if !isUserWhitelisted(logger, discord, bot, config.WhitelistedRoles, message.Author.ID) {
-		logger.Sugar().Debugf(
-			"User %s(%s) is not allowed to execute wipe command",
-			message.Author.Username,
-			message.Author.ID,
-		)
+	job := &WipeJob{
+		GuildID:   pending.GuildID,
+		ChannelID: pending.ChannelID,
+		Author:    interactionUser(interaction),
+		Filter:    pending.Filter,
+		Total:     pending.Preview.Count,
+	}
 
+	if err := startWipeJob(logger, discord, bot, job); err != nil {
+		logger.Info("Failed to start wipe job", zap.Error(err))
+		updateComponentMessage(logger, discord, interaction, fmt.Sprintf("Failed to start wipe: %s", err.Error()))
 		return
 	}
 
-	errors := 5
-	deletedMessages := 0
+	commandName := wipeCommandName(bot.Config().ForGuild(pending.GuildID).Commands.Wipe)
+	updateComponentMessage(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` started, deleting %d messages. Use `/%s %s %s` to stop it.", job.ID, job.Total, commandName, WipeSubcommandCancel, job.ID))
+}
 
-	firstMessage := ""
+// WipeCursor is the position of the wipe scan, it is kept in the checkpoint so the wipe can be resumed
+type WipeCursor struct {
+	// Before is the ID of the oldest scanned message, empty value means the newest message in the channel
+	Before string `json:"before"`
+	// Selected is the number of messages matching the filter so far
+	Selected int `json:"selected"`
+	// Kept is the number of messages retained by the retention policy so far
+	Kept int `json:"kept"`
+	// StartedAt is the time the filter cutoff is computed from
+	StartedAt time.Time `json:"started_at"`
+}
+
+// scanWipeMessages calls handle for every page of messages matching the filter, starting from the newest
+// one older than the cursor. The cursor is moved before the page is handled.
+// Scanning stops at the cutoff time of the filter or when filter count of messages is selected.
+func scanWipeMessages(
+	ctx context.Context,
+	logger *zap.Logger,
+	discord *discordgo.Session,
+	channelID string,
+	cursor *WipeCursor,
+	filter WipeFilter,
+	handle func(messages []*discordgo.Message) error,
+) error {
+	errors := 5
+	cutoff := filter.Cutoff(cursor.StartedAt)
 
-	wipeCtx, wipeCancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer wipeCancel()
 	for {
 		if errors < 1 {
-			logger.Error("Too many errors during wipe command exiting")
-			return
+			return fmt.Errorf("too many errors when fetching messages")
 		}
 
-		logger.Info("Getting messages to be deleted")
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		logger.Debug("Getting messages to be deleted")
 		messages, err := func() ([]*discordgo.Message, error) {
-			ctx, cancel := context.WithTimeout(wipeCtx, DefaultRequestTimeout)
+			reqCtx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
 			defer cancel()
 
 			return discord.ChannelMessages(
-				message.ChannelID,
-				1,
-				firstMessage,
+				channelID,
+				WipePageSize,
+				cursor.Before,
 				"",
 				"",
-				discordgo.WithContext(ctx),
+				discordgo.WithContext(reqCtx),
 				discordgo.WithClient(DefaultHttpClient(DefaultRequestTimeout)),
+				discordgo.WithRetryOnRatelimit(true),
 			)
 		}()
 
-		if firstMessage == "" {
-			firstMessage = message.ID
-			// continue
-		}
-
 		if err != nil {
 			errors--
 			logger.Error("Failed to fetch messages", zap.Error(err))
@@ -120,41 +304,159 @@ func commandWipe(
 
 		// no more messages
 		if len(messages) < 1 {
-			break
+			return nil
 		}
+		cursor.Before = messages[len(messages)-1].ID
 
-		m := messages[0]
-		bot.wipedMessages.Add(m.ID, true)
-		time.Sleep(100 * time.Millisecond)
+		matched := []*discordgo.Message{}
+		finished := false
+		for _, m := range messages {
+			// Messages are returned from the newest, so all the next messages are too old
+			if !cutoff.IsZero() && m.Timestamp.Before(cutoff) {
+				finished = true
+				break
+			}
 
-		if err := func() error {
-			// rl.Wait(wipeCtx)
-			ctx, cancel := context.WithTimeout(wipeCtx, DefaultRequestTimeout)
-			defer cancel()
+			if !filter.Match(m) {
+				continue
+			}
 
-			logger.Sugar().Infof("Deleting message: %s written %s", m.ID, m.Timestamp)
-			return discord.ChannelMessageDelete(
-				message.ChannelID,
-				m.ID,
-				discordgo.WithContext(ctx),
-				discordgo.WithClient(DefaultHttpClient(DefaultRequestTimeout)),
-				discordgo.WithRetryOnRatelimit(true),
-				discordgo.WithRestRetries(5),
-			)
-		}(); err != nil {
-			errors--
-			logger.Error("Failed to delete message", zap.Error(err))
+			if filter.Retain(m, cursor.Kept, cursor.StartedAt) {
+				cursor.Kept++
+				continue
+			}
+
+			if filter.Count > 0 && cursor.Selected >= filter.Count {
+				finished = true
+				break
+			}
+
+			matched = append(matched, m)
+			cursor.Selected++
+		}
+
+		if len(matched) > 0 {
+			if err := handle(matched); err != nil {
+				return err
+			}
 		}
 
-		deletedMessages++
+		if finished {
+			return nil
+		}
 	}
+}
+
+// wipeMessages deletes messages matching the filter from the newest one older than the cursor.
+// Messages younger than 14 days are deleted in bulk, older ones one by one. The number of messages
+// deleted from the page is passed to onProgress after every page. Every page is recorded in the
+// transcript before it is deleted, the transcript can be nil. It returns the number of deleted messages.
+func wipeMessages(
+	ctx context.Context,
+	logger *zap.Logger,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	channelID string,
+	cursor *WipeCursor,
+	filter WipeFilter,
+	transcript *Transcript,
+	onProgress func(deleted int),
+) (int, error) {
+	errors := 5
+	deletedMessages := 0
+
+	err := scanWipeMessages(ctx, logger, discord, channelID, cursor, filter, func(messages []*discordgo.Message) error {
+		if transcript != nil {
+			if err := transcript.Add(messages); err != nil {
+				logger.Warn("failed to record messages in the transcript journal", zap.Error(err))
+			}
+		}
+
+		deletedBeforePage := deletedMessages
+
+		bulk, single := []string{}, []*discordgo.Message{}
+		for _, m := range messages {
+			bot.wipedMessages.Add(m.ID, true)
+			if time.Since(m.Timestamp) < BulkDeleteMaxAge {
+				bulk = append(bulk, m.ID)
+			} else {
+				single = append(single, m)
+			}
+		}
+
+		if len(bulk) > 0 {
+			err := func() error {
+				reqCtx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+				defer cancel()
+
+				logger.Sugar().Infof("Bulk deleting %d messages", len(bulk))
+				return discord.ChannelMessagesBulkDelete(
+					channelID,
+					bulk,
+					discordgo.WithContext(reqCtx),
+					discordgo.WithClient(DefaultHttpClient(DefaultRequestTimeout)),
+					discordgo.WithRetryOnRatelimit(true),
+					discordgo.WithRestRetries(5),
+				)
+			}()
+
+			if err != nil {
+				// Canceled job is not the delete error, the rest of the page is not deleted one by one
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+
+				// Fallback to deleting messages one by one
+				errors--
+				logger.Error("Failed to bulk delete messages", zap.Error(err))
+				for _, m := range messages {
+					if slices.Contains(bulk, m.ID) {
+						single = append(single, m)
+					}
+				}
+			} else {
+				deletedMessages += len(bulk)
+			}
+		}
+
+		for _, m := range single {
+			if err := ctx.Err(); err != nil {
+				onProgress(deletedMessages - deletedBeforePage)
+				return err
+			}
+
+			if errors < 1 {
+				return fmt.Errorf("too many errors when deleting messages")
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			if err := func() error {
+				reqCtx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+				defer cancel()
+
+				logger.Sugar().Infof("Deleting message: %s written %s", m.ID, m.Timestamp)
+				return discord.ChannelMessageDelete(
+					channelID,
+					m.ID,
+					discordgo.WithContext(reqCtx),
+					discordgo.WithClient(DefaultHttpClient(DefaultRequestTimeout)),
+					discordgo.WithRetryOnRatelimit(true),
+					discordgo.WithRestRetries(5),
+				)
+			}(); err != nil {
+				errors--
+				logger.Error("Failed to delete message", zap.Error(err))
+				continue
+			}
+
+			deletedMessages++
+		}
+
+		onProgress(deletedMessages - deletedBeforePage)
 
-	logMessage := fmt.Sprintf("Wipe channel command received\n=================================\nAuthor: <@%s>\nChannel: <#%s>\nMessages deleted: %d",
-		message.Author.ID,
-		message.ChannelID,
-		deletedMessages,
-	)
-	logger.Info(logMessage)
+		return nil
+	})
 
-	discord.ChannelMessageSend(reportChannel, logMessage)
+	return deletedMessages, err
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -11,19 +14,85 @@ type Config struct {
 	Debug           bool   `toml:"debug"`
 	DiscordAPIDebug bool   `toml:"discord_api_debug"`
 	BotToken        string `toml:"bot_token"`
-	ReportChannel   string `toml:"report_channel"`
 
 	MessageKeepTrackCount int `toml:"messages_keep_track_count"`
 
-	Features ConfigFeatures `toml:"features"`
-	Commands ConfigCommands `toml:"commands"`
+	MessageStore ConfigMessageStore `toml:"message_store"`
+	WipeJobs     ConfigWipeJobs     `toml:"wipe_jobs"`
+	LinkResolver ConfigLinkResolver `toml:"link_resolver"`
+
+	// Top level guild config is the default for all the guilds
+	ConfigGuild
+
+	// Guilds contains the effective config for guilds with overrides in the [guilds."<id>"] sections
+	Guilds    map[string]ConfigGuild    `toml:"-"`
+	RawGuilds map[string]toml.Primitive `toml:"guilds"`
+
+	// undecodedKeys are keys from the config file that do not match any option
+	undecodedKeys []string
+}
+
+type ConfigGuild struct {
+	ReportChannel string `toml:"report_channel"`
+
+	Features  ConfigFeatures  `toml:"features"`
+	Commands  ConfigCommands  `toml:"commands"`
+	Retention ConfigRetention `toml:"retention"`
 
 	ModeratedChannels []string `toml:"moderated_channels"`
 	ModeratedKeywords []string `toml:"moderated_keywords"`
 }
 
+// ConfigRetention deletes old messages in the channels on the schedule
+type ConfigRetention struct {
+	Enabled bool `toml:"enabled"`
+	// Schedule is the cron expression, e.g. "0 3 * * *" runs every day at 3:00
+	Schedule string                   `toml:"schedule"`
+	Channels []ConfigRetentionChannel `toml:"channels"`
+}
+
+type ConfigRetentionChannel struct {
+	Channel string `toml:"channel"`
+	// MaxAge deletes messages older than the given time, 0 means no limit
+	MaxAge time.Duration `toml:"max_age"`
+	// MaxCount keeps only the given number of the newest messages, 0 means no limit
+	MaxCount int `toml:"max_count"`
+}
+
+type ConfigWipeJobs struct {
+	// Path is the directory where checkpoints of running wipe jobs are kept
+	Path string `toml:"path"`
+	// Resume is one of: ask, auto
+	Resume string `toml:"resume"`
+}
+
+type ConfigMessageStore struct {
+	// Type is one of: memory, bolt
+	Type      string        `toml:"type"`
+	Path      string        `toml:"path"`
+	Retention time.Duration `toml:"retention"`
+}
+
+// ConfigLinkResolver limits what the bot can open when it follows links posted by users. Loopback, private,
+// link-local and cloud metadata addresses are always denied unless they are in AllowedCIDRs.
+type ConfigLinkResolver struct {
+	AllowedCIDRs []string `toml:"allowed_cidrs"`
+	DeniedCIDRs  []string `toml:"denied_cidrs"`
+	// MaxBodySize is the number of bytes read from every page to find redirects and invitations
+	MaxBodySize int64 `toml:"max_body_size"`
+}
+
 type ConfigCommands struct {
-	Wipe ConfigCommandWipe `toml:"wipe"`
+	Wipe    ConfigCommandWipe    `toml:"wipe"`
+	Restore ConfigCommandRestore `toml:"restore"`
+}
+
+type ConfigCommandRestore struct {
+	Enabled bool   `toml:"enabled"`
+	Command string `toml:"command"`
+
+	WhitelistedRoles []string `toml:"whitelisted_roles"`
+	ActiveChannels   []string `toml:"active_channels"`
 }
 
 type ConfigCommandWipe struct {
@@ -32,15 +101,64 @@ type ConfigCommandWipe struct {
 
 	WhitelistedRoles []string `toml:"whitelisted_roles"`
 	ActiveChannels   []string `toml:"active_channels"`
+
+	// TranscriptsPath is the directory where transcripts of wiped messages are kept
+	TranscriptsPath string `toml:"transcripts_path"`
 }
 
 type ConfigSuspiciousMessage struct {
+	Enabled bool `toml:"enabled"`
+	// Keywords are compiled when the config is decoded, see Keyword for the syntax
+	Keywords         []Keyword `toml:"keywords"`
+	WhiteListedRoles []string  `toml:"whitelisted_roles"`
+
+	// Rules are keywords with own weight, Keywords have the score.keyword_weight
+	Rules []ConfigKeywordRule `toml:"rules"`
+	Score ConfigScore         `toml:"score"`
+}
+
+type ConfigKeywordRule struct {
+	Keyword Keyword `toml:"keyword"`
+	Weight  float64 `toml:"weight"`
+}
+
+// ConfigScore sets weights of signals added to the score of the message. The message is
+// reported when its score reaches ReportThreshold and deleted when it reaches DeleteThreshold.
+type ConfigScore struct {
+	// ReportThreshold 0 means the default, 1
+	ReportThreshold float64 `toml:"report_threshold"`
+	// DeleteThreshold 0 means messages are never deleted
+	DeleteThreshold float64 `toml:"delete_threshold"`
+
+	// KeywordWeight 0 means the default, 1
+	KeywordWeight float64 `toml:"keyword_weight"`
+	// NewAccountWeight is added when the account of the author is younger than NewAccountAge
+	NewAccountAge    time.Duration `toml:"new_account_age"`
+	NewAccountWeight float64       `toml:"new_account_weight"`
+	LinkWeight       float64       `toml:"link_weight"`
+	// MentionWeight is added for every mentioned user and role, and for @everyone
+	MentionWeight float64 `toml:"mention_weight"`
+	// FirstMessageWeight is added for the first message of the recently joined member
+	FirstMessageWeight float64 `toml:"first_message_weight"`
+}
+
+type ConfigReportDeletedMessages struct {
 	Enabled          bool     `toml:"enabled"`
-	Keywords         []string `toml:"keywords"`
 	WhiteListedRoles []string `toml:"whitelisted_roles"`
+
+	Attachments ConfigAttachments `toml:"attachments"`
 }
 
-type ConfigReportDeletedMessages struct {
+type ConfigAttachments struct {
+	Enabled bool          `toml:"enabled"`
+	Path    string        `toml:"path"`
+	TTL     time.Duration `toml:"ttl"`
+	// MaxSize is the max size of the single attachment in bytes
+	MaxSize  int64 `toml:"max_size"`
+	MaxCount int   `toml:"max_count"`
+}
+
+type ConfigReportEditedMessages struct {
 	Enabled          bool     `toml:"enabled"`
 	WhiteListedRoles []string `toml:"whitelisted_roles"`
 }
@@ -49,6 +167,16 @@ type ConfigDeleteInviteLinks struct {
 	Enabled          bool     `toml:"enabled"`
 	WhiteListedRoles []string `toml:"whitelisted_roles"`
 	WarnMessage      string   `toml:"warn_message"`
+
+	// Recent messages of the author in the channel are checked together, so the invitation split into
+	// several messages is found. Window is the max age of these messages and WindowSize is their max number.
+	Window     time.Duration `toml:"window"`
+	WindowSize int           `toml:"window_size"`
+
+	// Invitations to the current server and to AllowedGuilds are not deleted
+	AllowedGuilds []string `toml:"allowed_guilds"`
+	// Invitations to servers younger than NewServerAge are marked as high risk in the report
+	NewServerAge time.Duration `toml:"new_server_age"`
 }
 
 type ConfigFeatures struct {
@@ -56,6 +184,8 @@ type ConfigFeatures struct {
 
 	ReportDeletedMessages ConfigReportDeletedMessages `toml:"report_deleted_messages"`
 
+	ReportEditedMessages ConfigReportEditedMessages `toml:"report_edited_messages"`
+
 	DeleteInviteLinks ConfigDeleteInviteLinks `toml:"delete_invite_links"`
 }
 
@@ -67,9 +197,133 @@ func ReadConfigFile(configFilePath string) (*Config, error) {
 
 	config := &Config{}
 
-	if _, err = toml.Decode(string(configBytes), &config); err != nil {
+	md, err := toml.Decode(string(configBytes), &config)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	config.Guilds = map[string]ConfigGuild{}
+	for guildID, rawGuild := range config.RawGuilds {
+		// Start from the copy of defaults, so only keys given in the guild section are overridden
+		guildConfig, err := config.ConfigGuild.clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy default config for guild %s: %w", guildID, err)
+		}
+
+		if err := md.PrimitiveDecode(rawGuild, &guildConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse config for guild %s: %w", guildID, err)
+		}
+
+		config.Guilds[guildID] = guildConfig
+	}
+
+	// Guild sections are decoded above, so only keys that do not match any option are left
+	for _, key := range md.Undecoded() {
+		config.undecodedKeys = append(config.undecodedKeys, key.String())
+	}
+
 	return config, nil
 }
+
+// ForGuild returns the effective config for the given guild
+func (c Config) ForGuild(guildID string) ConfigGuild {
+	if guildConfig, found := c.Guilds[guildID]; found {
+		return guildConfig
+	}
+
+	return c.ConfigGuild
+}
+
+// AllGuilds returns the default config and configs of all the guilds with overrides
+func (c Config) AllGuilds() []ConfigGuild {
+	configs := []ConfigGuild{c.ConfigGuild}
+	for _, guildConfig := range c.Guilds {
+		configs = append(configs, guildConfig)
+	}
+
+	return configs
+}
+
+// maxMessageWindow returns the longest invite links window of all the guilds
+func (c Config) maxMessageWindow() time.Duration {
+	window := time.Duration(0)
+	for _, guildConfig := range c.AllGuilds() {
+		window = max(window, guildConfig.Features.DeleteInviteLinks.window())
+	}
+
+	return window
+}
+
+// AllModeratedChannels returns moderated channels from all the guilds
+func (c Config) AllModeratedChannels() []string {
+	channels := []string{}
+	for _, guildConfig := range c.AllGuilds() {
+		for _, channelID := range guildConfig.ModeratedChannels {
+			if !slices.Contains(channels, channelID) {
+				channels = append(channels, channelID)
+			}
+		}
+	}
+
+	return channels
+}
+
+// ReplaceChannel returns the copy of the config with references to the old channel replaced by the new one
+func (c *Config) ReplaceChannel(oldID, newID string) (*Config, error) {
+	replaced := *c
+
+	var err error
+	replaced.ConfigGuild, err = c.ConfigGuild.replaceChannel(oldID, newID)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced.Guilds = make(map[string]ConfigGuild, len(c.Guilds))
+	for guildID, guildConfig := range c.Guilds {
+		replaced.Guilds[guildID], err = guildConfig.replaceChannel(oldID, newID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy config of guild %s: %w", guildID, err)
+		}
+	}
+
+	return &replaced, nil
+}
+
+func (c ConfigGuild) replaceChannel(oldID, newID string) (ConfigGuild, error) {
+	replaced, err := c.clone()
+	if err != nil {
+		return replaced, err
+	}
+
+	if replaced.ReportChannel == oldID {
+		replaced.ReportChannel = newID
+	}
+	for _, channels := range [][]string{replaced.ModeratedChannels, replaced.Commands.Wipe.ActiveChannels, replaced.Commands.Restore.ActiveChannels} {
+		for idx := range channels {
+			if channels[idx] == oldID {
+				channels[idx] = newID
+			}
+		}
+	}
+	for idx := range replaced.Retention.Channels {
+		if replaced.Retention.Channels[idx].Channel == oldID {
+			replaced.Retention.Channels[idx].Channel = newID
+		}
+	}
+
+	return replaced, nil
+}
+
+// clone returns deep copy of the config. Decoding into the struct reuses its slices,
+// so overrides cannot be decoded into the shallow copy of defaults.
+func (c ConfigGuild) clone() (ConfigGuild, error) {
+	copied := ConfigGuild{}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return copied, err
+	}
+
+	err = json.Unmarshal(data, &copied)
+	return copied, err
+}
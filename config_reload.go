@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const configWatchInterval = 10 * time.Second
+
+// WatchConfig reloads the config on SIGHUP or when the config file is modified
+func (b *DiscordBot) WatchConfig(ctx context.Context, logger *zap.Logger, discord *discordgo.Session, configPath string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	t := time.NewTicker(configWatchInterval)
+	defer t.Stop()
+
+	lastModified := configModTime(configPath)
+	for {
+		select {
+		case <-hup:
+			logger.Info("SIGHUP received, reloading config")
+		case <-t.C:
+			modTime := configModTime(configPath)
+			if modTime.Equal(lastModified) {
+				continue
+			}
+			logger.Info("Config file modified, reloading config")
+		case <-ctx.Done():
+			return
+		}
+
+		lastModified = configModTime(configPath)
+		reloadConfig(logger, discord, b, configPath)
+	}
+}
+
+func configModTime(configPath string) time.Time {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// reloadConfig reads and validates the config file and swaps it in the bot.
+// When the new config is invalid, the old one is kept.
+func reloadConfig(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, configPath string) {
+	oldConfig := bot.Config()
+
+	reportFailure := func(err error) {
+		sendReport(logger, discord, oldConfig.ReportChannel, Report{
+			Title:   "Failed to reload config, the old config is kept",
+			Color:   ReportColorDeleted,
+			Feature: "config_reload",
+			Content: err.Error(),
+		})
+	}
+
+	newConfig, err := ReadConfigFile(configPath)
+	if err != nil {
+		logger.Error("failed to read config", zap.Error(err))
+		reportFailure(err)
+		return
+	}
+
+	if err := newConfig.Validate(); err != nil {
+		logger.Error("invalid config", zap.Error(err))
+		reportFailure(err)
+		return
+	}
+
+	newChannels := []string{}
+	for _, channelID := range newConfig.AllModeratedChannels() {
+		if !slices.Contains(oldConfig.AllModeratedChannels(), channelID) {
+			newChannels = append(newChannels, channelID)
+		}
+	}
+	if err := registerChannelsModeration(logger, discord, newChannels); err != nil {
+		logger.Error("failed to register new moderated channels", zap.Error(err))
+		reportFailure(err)
+		return
+	}
+
+	if err := bot.UpdateConfig(newConfig); err != nil {
+		logger.Error("failed to apply config", zap.Error(err))
+		reportFailure(err)
+		return
+	}
+
+	discord.State.Lock()
+	discord.State.MaxMessageCount = newConfig.MessageKeepTrackCount
+	discord.State.Unlock()
+
+	logger.Info("Config reloaded")
+
+	// Commands can be renamed, enabled or disabled in the new config
+	if err := registerApplicationCommands(logger, discord, bot); err != nil {
+		logger.Error("failed to register application commands", zap.Error(err))
+	}
+
+	diff, err := configDiff(oldConfig, newConfig)
+	if err != nil {
+		logger.Warn("failed to compute config diff", zap.Error(err))
+	}
+
+	report := Report{
+		Title:   "Config reloaded",
+		Color:   ReportColorCommand,
+		Feature: "config_reload",
+		Content: diff,
+	}
+
+	if restartRequired := configRestartRequired(oldConfig, newConfig); len(restartRequired) > 0 {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{
+			Name:  "Changes applied after restart",
+			Value: strings.Join(restartRequired, "\n"),
+		})
+	}
+
+	sendReport(logger, discord, newConfig.ReportChannel, report)
+}
+
+// configRestartRequired returns options that are used only when the bot starts
+func configRestartRequired(oldConfig, newConfig *Config) []string {
+	options := []string{}
+
+	if oldConfig.BotToken != newConfig.BotToken {
+		options = append(options, "bot_token")
+	}
+	if oldConfig.MessageStore != newConfig.MessageStore {
+		options = append(options, "message_store")
+	}
+	if oldConfig.WipeJobs.Path != newConfig.WipeJobs.Path {
+		options = append(options, "wipe_jobs.path")
+	}
+
+	oldAttachments := oldConfig.Features.ReportDeletedMessages.Attachments
+	newAttachments := newConfig.Features.ReportDeletedMessages.Attachments
+	if oldAttachments.Path != newAttachments.Path || oldAttachments.TTL != newAttachments.TTL {
+		options = append(options, "features.report_deleted_messages.attachments")
+	}
+
+	return options
+}
+
+// configDiff returns changed lines of the config dumped to TOML, every change is
+// preceded by the table it belongs to
+func configDiff(oldConfig, newConfig *Config) (string, error) {
+	oldDump, err := dumpConfig(oldConfig)
+	if err != nil {
+		return "", err
+	}
+
+	newDump, err := dumpConfig(newConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if oldDump == newDump {
+		return "No changes", nil
+	}
+
+	result := []string{}
+	table, reportedTable := "", ""
+	for _, segment := range DiffLines(oldDump, newDump) {
+		line := strings.TrimSpace(segment.Text)
+		if strings.HasPrefix(line, "[") {
+			table = line
+		}
+
+		if segment.Op == DiffEqual || line == "" {
+			continue
+		}
+
+		if table != reportedTable {
+			result = append(result, "  "+table)
+			reportedTable = table
+		}
+
+		prefix := "+ "
+		if segment.Op == DiffRemoved {
+			prefix = "- "
+		}
+		result = append(result, prefix+line)
+	}
+
+	return strings.Join(result, "\n"), nil
+}
+
+func dumpConfig(config *Config) (string, error) {
+	dumped := *config
+	if dumped.BotToken != "" {
+		dumped.BotToken = "<redacted>"
+	}
+	dumped.RawGuilds = nil
+
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(dumped); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if len(config.Guilds) > 0 {
+		guilds := struct {
+			Guilds map[string]ConfigGuild `toml:"guilds"`
+		}{Guilds: config.Guilds}
+
+		if err := toml.NewEncoder(&buf).Encode(guilds); err != nil {
+			return "", fmt.Errorf("failed to encode guilds config: %w", err)
+		}
+	}
+
+	return buf.String(), nil
+}
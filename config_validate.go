@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+)
+
+type ConfigIssueSeverity string
+
+const (
+	ConfigIssueError   ConfigIssueSeverity = "ERROR"
+	ConfigIssueWarning ConfigIssueSeverity = "WARNING"
+)
+
+type ConfigIssue struct {
+	Severity ConfigIssueSeverity
+	Key      string
+	Message  string
+}
+
+func (i ConfigIssue) String() string {
+	return fmt.Sprintf("%-7s %s: %s", i.Severity, i.Key, i.Message)
+}
+
+// Validate checks if the config can be used by the bot. Only errors are returned, warnings are ignored.
+func (c *Config) Validate() error {
+	errs := []error{}
+	for _, issue := range c.Check() {
+		if issue.Severity == ConfigIssueError {
+			errs = append(errs, fmt.Errorf("%s: %s", issue.Key, issue.Message))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check runs checks that do not require connection to discord
+func (c *Config) Check() []ConfigIssue {
+	issues := []ConfigIssue{}
+
+	for _, key := range c.undecodedKeys {
+		issues = append(issues, ConfigIssue{ConfigIssueError, key, "unknown key"})
+	}
+
+	if strings.TrimSpace(c.BotToken) == "" {
+		issues = append(issues, ConfigIssue{ConfigIssueError, "bot_token", "is empty"})
+	}
+
+	switch c.MessageStore.Type {
+	case "", MessageStoreMemory, MessageStoreBolt:
+	default:
+		issues = append(issues, ConfigIssue{
+			ConfigIssueError,
+			"message_store.type",
+			fmt.Sprintf("unknown type %q, expected %q or %q", c.MessageStore.Type, MessageStoreMemory, MessageStoreBolt),
+		})
+	}
+
+	switch c.WipeJobs.Resume {
+	case "", WipeJobsResumeAsk, WipeJobsResumeAuto:
+	default:
+		issues = append(issues, ConfigIssue{
+			ConfigIssueError,
+			"wipe_jobs.resume",
+			fmt.Sprintf("unknown value %q, expected %q or %q", c.WipeJobs.Resume, WipeJobsResumeAsk, WipeJobsResumeAuto),
+		})
+	}
+
+	if _, err := parsePrefixes(c.LinkResolver.AllowedCIDRs); err != nil {
+		issues = append(issues, ConfigIssue{ConfigIssueError, "link_resolver.allowed_cidrs", err.Error()})
+	}
+	if _, err := parsePrefixes(c.LinkResolver.DeniedCIDRs); err != nil {
+		issues = append(issues, ConfigIssue{ConfigIssueError, "link_resolver.denied_cidrs", err.Error()})
+	}
+	if c.LinkResolver.MaxBodySize < 0 {
+		issues = append(issues, ConfigIssue{ConfigIssueError, "link_resolver.max_body_size", "cannot be negative"})
+	}
+
+	issues = append(issues, c.ConfigGuild.check("")...)
+	for _, guildID := range sortedKeys(c.Guilds) {
+		issues = append(issues, c.Guilds[guildID].check(fmt.Sprintf("guilds.%q.", guildID))...)
+	}
+
+	return issues
+}
+
+var applicationCommandNameRegex = regexp.MustCompile(`^[-_a-z0-9]{1,32}$`)
+
+var printfVerbRegex = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+func (c ConfigGuild) check(prefix string) []ConfigIssue {
+	issues := []ConfigIssue{}
+
+	if strings.TrimSpace(c.ReportChannel) == "" {
+		issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "report_channel", "is empty"})
+	}
+
+	featuresEnabled := c.Features.SuspiciousMessage.Enabled ||
+		c.Features.ReportDeletedMessages.Enabled ||
+		c.Features.ReportEditedMessages.Enabled ||
+		c.Features.DeleteInviteLinks.Enabled
+	if attachments := c.Features.ReportDeletedMessages.Attachments; attachments.Enabled && attachments.MaxSize > reportUploadLimit {
+		issues = append(issues, ConfigIssue{
+			ConfigIssueWarning,
+			prefix + "features.report_deleted_messages.attachments.max_size",
+			fmt.Sprintf("is larger than %d bytes, such files cannot be uploaded to servers without boosts", reportUploadLimit),
+		})
+	}
+
+	if featuresEnabled && len(c.ModeratedChannels) < 1 {
+		issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "moderated_channels", "is empty, moderation features are not used"})
+	}
+
+	if c.Features.SuspiciousMessage.Enabled && len(c.Features.SuspiciousMessage.Keywords) < 1 && len(c.Features.SuspiciousMessage.Rules) < 1 {
+		issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "features.suspicious_messages.keywords", "and rules are empty, messages are scored only by other signals"})
+	}
+
+	if score := c.Features.SuspiciousMessage.Score; c.Features.SuspiciousMessage.Enabled {
+		key := prefix + "features.suspicious_messages.score"
+		if score.ReportThreshold < 0 || score.DeleteThreshold < 0 {
+			issues = append(issues, ConfigIssue{ConfigIssueError, key, "thresholds cannot be negative"})
+		}
+		if score.DeleteThreshold > 0 && score.DeleteThreshold < score.ReportThreshold {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, key + ".delete_threshold", "is lower than report_threshold, messages are deleted before they are reported"})
+		}
+		if score.NewAccountWeight != 0 && score.NewAccountAge <= 0 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, key + ".new_account_age", "is not set, new_account_weight is not used"})
+		}
+	}
+
+	if c.Features.DeleteInviteLinks.Enabled {
+		key := prefix + "features.delete_invite_links.warn_message"
+
+		verbs := printfVerbRegex.FindAllString(c.Features.DeleteInviteLinks.WarnMessage, -1)
+		mentions := 0
+		for _, verb := range verbs {
+			switch verb {
+			case "%%":
+			case "%s":
+				mentions++
+			default:
+				issues = append(issues, ConfigIssue{ConfigIssueError, key, fmt.Sprintf("unsupported verb %s, only single %%s for the user id is allowed", verb)})
+			}
+		}
+
+		if mentions != 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueError, key, fmt.Sprintf("expected exactly one %%s for the user id, got %d", mentions)})
+		}
+
+		if c.Features.DeleteInviteLinks.Window < 0 || c.Features.DeleteInviteLinks.WindowSize < 0 {
+			issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "features.delete_invite_links.window", "window and window_size cannot be negative"})
+		}
+		if c.Features.DeleteInviteLinks.NewServerAge < 0 {
+			issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "features.delete_invite_links.new_server_age", "cannot be negative"})
+		}
+		if c.Features.DeleteInviteLinks.Window > cacheValid {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "features.delete_invite_links.window", fmt.Sprintf("is longer than %s, every message of the author is checked with all the messages posted in the window", cacheValid)})
+		}
+	}
+
+	if c.Commands.Wipe.Enabled {
+		if !applicationCommandNameRegex.MatchString(wipeCommandName(c.Commands.Wipe)) {
+			issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "commands.wipe.command", "slash command name must be 1-32 lowercase letters, digits, - or _"})
+		}
+		if len(c.Commands.Wipe.ActiveChannels) < 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "commands.wipe.active_channels", "is empty, command cannot be used"})
+		}
+		if len(c.Commands.Wipe.WhitelistedRoles) < 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "commands.wipe.whitelisted_roles", "is empty, nobody can use the command"})
+		}
+	}
+
+	if c.Commands.Restore.Enabled {
+		if !applicationCommandNameRegex.MatchString(restoreCommandName(c.Commands.Restore)) {
+			issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "commands.restore.command", "slash command name must be 1-32 lowercase letters, digits, - or _"})
+		}
+		if len(c.Commands.Restore.ActiveChannels) < 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "commands.restore.active_channels", "is empty, command cannot be used"})
+		}
+		if len(c.Commands.Restore.WhitelistedRoles) < 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "commands.restore.whitelisted_roles", "is empty, nobody can use the command"})
+		}
+	}
+
+	if c.Retention.Enabled {
+		if _, err := cron.ParseStandard(c.Retention.Schedule); err != nil {
+			issues = append(issues, ConfigIssue{ConfigIssueError, prefix + "retention.schedule", fmt.Sprintf("invalid cron expression: %s", err.Error())})
+		}
+		if len(c.Retention.Channels) < 1 {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, prefix + "retention.channels", "is empty, no message is deleted"})
+		}
+		for idx, channel := range c.Retention.Channels {
+			key := fmt.Sprintf("%sretention.channels[%d]", prefix, idx)
+			if strings.TrimSpace(channel.Channel) == "" {
+				issues = append(issues, ConfigIssue{ConfigIssueError, key + ".channel", "is empty"})
+			}
+			if channel.MaxAge < 0 || channel.MaxCount < 0 {
+				issues = append(issues, ConfigIssue{ConfigIssueError, key, "max_age and max_count cannot be negative"})
+			}
+			if channel.MaxAge == 0 && channel.MaxCount == 0 {
+				issues = append(issues, ConfigIssue{ConfigIssueError, key, "max_age or max_count must be set, otherwise all the messages are deleted"})
+			}
+		}
+	}
+
+	return issues
+}
+
+// CheckOnline verifies that channels and roles from the config exist in the guilds the bot belongs to
+func (c *Config) CheckOnline(discord *discordgo.Session) []ConfigIssue {
+	guilds, err := discord.UserGuilds(200, "", "", false)
+	if err != nil {
+		return []ConfigIssue{{ConfigIssueError, "bot_token", fmt.Sprintf("failed to get guilds of the bot: %s", err.Error())}}
+	}
+
+	issues := []ConfigIssue{}
+	allRoles := map[string][]string{}
+	for _, guild := range guilds {
+		roles, err := discord.GuildRoles(guild.ID)
+		if err != nil {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, fmt.Sprintf("guilds.%q", guild.ID), fmt.Sprintf("failed to get roles: %s", err.Error())})
+			continue
+		}
+
+		for _, role := range roles {
+			allRoles[guild.ID] = append(allRoles[guild.ID], role.Name)
+		}
+	}
+
+	for _, guildID := range sortedKeys(c.Guilds) {
+		if _, found := allRoles[guildID]; !found {
+			issues = append(issues, ConfigIssue{ConfigIssueWarning, fmt.Sprintf("guilds.%q", guildID), "bot does not belong to the guild"})
+		}
+	}
+
+	// Default config is used by every guild, so its channels and roles may belong to any guild
+	issues = append(issues, c.ConfigGuild.checkOnline(discord, "", "", allRoles)...)
+	for _, guildID := range sortedKeys(c.Guilds) {
+		issues = append(issues, c.Guilds[guildID].checkOnline(discord, fmt.Sprintf("guilds.%q.", guildID), guildID, allRoles)...)
+	}
+
+	return issues
+}
+
+func (c ConfigGuild) checkOnline(discord *discordgo.Session, prefix string, guildID string, allRoles map[string][]string) []ConfigIssue {
+	issues := []ConfigIssue{}
+
+	checkChannel := func(key, channelID string) {
+		if channelID == "" {
+			return
+		}
+
+		channel, err := discord.Channel(channelID)
+		if err != nil {
+			issues = append(issues, ConfigIssue{ConfigIssueError, key, fmt.Sprintf("channel %s not found: %s", channelID, err.Error())})
+			return
+		}
+
+		if guildID != "" && channel.GuildID != guildID {
+			issues = append(issues, ConfigIssue{ConfigIssueError, key, fmt.Sprintf("channel %s(%s) belongs to another guild %s", channel.Name, channelID, channel.GuildID)})
+		}
+	}
+
+	checkRoles := func(key string, roles []string) {
+		for _, role := range roles {
+			found := false
+			for roleGuildID, guildRoles := range allRoles {
+				if (guildID == "" || roleGuildID == guildID) && slices.Contains(guildRoles, role) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				issues = append(issues, ConfigIssue{ConfigIssueError, key, fmt.Sprintf("role %q not found", role)})
+			}
+		}
+	}
+
+	checkChannel(prefix+"report_channel", c.ReportChannel)
+	for _, channelID := range c.ModeratedChannels {
+		checkChannel(prefix+"moderated_channels", channelID)
+	}
+	for _, channelID := range c.Commands.Wipe.ActiveChannels {
+		checkChannel(prefix+"commands.wipe.active_channels", channelID)
+	}
+	for _, channelID := range c.Commands.Restore.ActiveChannels {
+		checkChannel(prefix+"commands.restore.active_channels", channelID)
+	}
+	for idx, channel := range c.Retention.Channels {
+		checkChannel(fmt.Sprintf("%sretention.channels[%d].channel", prefix, idx), channel.Channel)
+	}
+
+	checkRoles(prefix+"features.suspicious_messages.whitelisted_roles", c.Features.SuspiciousMessage.WhiteListedRoles)
+	checkRoles(prefix+"features.report_deleted_messages.whitelisted_roles", c.Features.ReportDeletedMessages.WhiteListedRoles)
+	checkRoles(prefix+"features.report_edited_messages.whitelisted_roles", c.Features.ReportEditedMessages.WhiteListedRoles)
+	checkRoles(prefix+"features.delete_invite_links.whitelisted_roles", c.Features.DeleteInviteLinks.WhiteListedRoles)
+	checkRoles(prefix+"commands.wipe.whitelisted_roles", c.Commands.Wipe.WhitelistedRoles)
+	checkRoles(prefix+"commands.restore.whitelisted_roles", c.Commands.Restore.WhitelistedRoles)
+
+	return issues
+}
+
+// PrintConfigIssues writes issues in the human readable form. It returns true when there is any error.
+func PrintConfigIssues(w io.Writer, configPath string, issues []ConfigIssue) bool {
+	errorsCount, warningsCount := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == ConfigIssueError {
+			errorsCount++
+		} else {
+			warningsCount++
+		}
+	}
+
+	fmt.Fprintf(w, "%s: %d error(s), %d warning(s)\n", configPath, errorsCount, warningsCount)
+	for _, issue := range issues {
+		fmt.Fprintf(w, "  %s\n", issue.String())
+	}
+
+	return errorsCount > 0
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffRemoved
+	DiffAdded
+)
+
+type DiffSegment struct {
+	Op   DiffOp
+	Text string
+}
+
+var (
+	diffTokenRegex = regexp.MustCompile(`\s+|\S+`)
+	// Words with the whitespace before them, the highlighted text is cut after the word, so the marker can close it
+	diffWordRegex = regexp.MustCompile(`\s*\S+`)
+)
+
+// DiffWords returns the word level diff between two texts. Whitespaces are
+// separate tokens, so joining the segments gives the original texts back.
+func DiffWords(before, after string) []DiffSegment {
+	return diffTokens(
+		diffTokenRegex.FindAllString(before, -1),
+		diffTokenRegex.FindAllString(after, -1),
+		true,
+	)
+}
+
+// DiffLines returns the line level diff between two texts, every line is the separate segment
+func DiffLines(before, after string) []DiffSegment {
+	return diffTokens(strings.Split(before, "\n"), strings.Split(after, "\n"), false)
+}
+
+// diffMaxCells limits the size of the LCS table, any member can edit the long message many times.
+// Texts with more changed tokens are shown as removed and added as a whole.
+const diffMaxCells = 256 * 1024
+
+func diffTokens(a, b []string, mergeSegments bool) []DiffSegment {
+	segments := []DiffSegment{}
+	appendSegment := func(op DiffOp, text string) {
+		if last := len(segments) - 1; mergeSegments && last >= 0 && segments[last].Op == op {
+			segments[last].Text += text
+			return
+		}
+		segments = append(segments, DiffSegment{Op: op, Text: text})
+	}
+
+	// Common prefix and suffix do not need the LCS table, usually only the small part of the message is edited
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, token := range a[:prefix] {
+		appendSegment(DiffEqual, token)
+	}
+	commonSuffix := b[len(b)-suffix:]
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if (len(a)+1)*(len(b)+1) > diffMaxCells {
+		for _, token := range a {
+			appendSegment(DiffRemoved, token)
+		}
+		for _, token := range b {
+			appendSegment(DiffAdded, token)
+		}
+		for _, token := range commonSuffix {
+			appendSegment(DiffEqual, token)
+		}
+
+		return segments
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			appendSegment(DiffEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			appendSegment(DiffRemoved, a[i])
+			i++
+		default:
+			appendSegment(DiffAdded, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		appendSegment(DiffRemoved, a[i])
+	}
+	for ; j < len(b); j++ {
+		appendSegment(DiffAdded, b[j])
+	}
+	for _, token := range commonSuffix {
+		appendSegment(DiffEqual, token)
+	}
+
+	return segments
+}
+
+// HighlightDiff renders one side of the diff as the escaped markdown, the result does not exceed the limit of characters.
+// Removed segments are struck through on the old side, added segments are bold on the new side. Too long text is cut
+// between words and the open marker is closed before the ellipsis, so the rest of the report is not struck through.
+func HighlightDiff(segments []DiffSegment, op DiffOp, limit int) string {
+	marker := "**"
+	if op == DiffRemoved {
+		marker = "~~"
+	}
+
+	result := strings.Builder{}
+	length := 0
+	// write adds the text when it leaves space for the reserved characters and the ellipsis
+	write := func(text string, reserved int) bool {
+		count := utf8.RuneCountInString(text)
+		if length+count+reserved+1 > limit {
+			return false
+		}
+
+		result.WriteString(text)
+		length += count
+		return true
+	}
+	truncate := func(closing string) string {
+		result.WriteString(closing + "…")
+		return result.String()
+	}
+
+	for _, segment := range segments {
+		switch segment.Op {
+		case DiffEqual:
+			for _, token := range diffTokenRegex.FindAllString(segment.Text, -1) {
+				if !write(escapeMarkdown(token), 0) {
+					return truncate("")
+				}
+			}
+		case op:
+			trimmed := strings.TrimSpace(segment.Text)
+			if trimmed == "" {
+				if !write(segment.Text, 0) {
+					return truncate("")
+				}
+				continue
+			}
+
+			// Markdown does not allow whitespaces right after or before the marker
+			start := strings.Index(segment.Text, trimmed)
+			if !write(segment.Text[:start], 0) {
+				return truncate("")
+			}
+
+			tokens := diffWordRegex.FindAllString(trimmed, -1)
+			if !write(marker+escapeMarkdown(tokens[0]), len(marker)) {
+				return truncate("")
+			}
+			for _, token := range tokens[1:] {
+				if !write(escapeMarkdown(token), len(marker)) {
+					return truncate(marker)
+				}
+			}
+			write(marker, 0)
+			if !write(segment.Text[start+len(trimmed):], 0) {
+				return truncate("")
+			}
+		}
+	}
+
+	return result.String()
+}
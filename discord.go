@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,25 +13,57 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-func Run(logger *zap.Logger, config *Config) error {
+func Run(logger *zap.Logger, configPath string, config *Config) error {
 	// create a session
 	discord, err := discordgo.New("Bot " + config.BotToken)
 
 	if err != nil {
 		return fmt.Errorf("failed to initialize bot: %w", err)
 	}
-	bot := NewDiscordBot(*config)
+
+	messages, err := NewMessageStore(config.MessageStore)
+	if err != nil {
+		return fmt.Errorf("failed to initialize message store: %w", err)
+	}
+	defer messages.Close()
+
+	// Attachments can be enabled per guild, but the store is shared, so its path and TTL are taken from the defaults
+	var attachments *BlobStore
+	for _, guildConfig := range config.AllGuilds() {
+		if !guildConfig.Features.ReportDeletedMessages.Attachments.Enabled {
+			continue
+		}
+
+		attachmentsConfig := config.Features.ReportDeletedMessages.Attachments
+		attachments, err = NewBlobStore(attachmentsConfig.Path, attachmentsConfig.TTL)
+		if err != nil {
+			return fmt.Errorf("failed to initialize attachments store: %w", err)
+		}
+		break
+	}
+
+	wipeCheckpoints, err := NewWipeCheckpoints(config.WipeJobs.Path)
+	if err != nil {
+		return fmt.Errorf("failed to initialize wipe jobs checkpoints: %w", err)
+	}
+
+	bot, err := NewDiscordBot(config, messages, attachments, wipeCheckpoints)
+	if err != nil {
+		return fmt.Errorf("failed to initialize bot: %w", err)
+	}
 
 	// add a event handler
 	discord.AddHandler(readyHandler(logger, bot))
-	discord.AddHandler(newMessageHandler(logger, bot, *config))
-	discord.AddHandler(deleteMessageHandler(logger, *config, bot))
+	discord.AddHandler(newMessageHandler(logger, bot))
+	discord.AddHandler(deleteMessageHandler(logger, bot))
+	discord.AddHandler(updateMessageHandler(logger, bot))
+	discord.AddHandler(interactionCreateHandler(logger, bot))
 
 	// open session
 	discord.Open()
 	defer discord.Close() // close session, after function termination
 
-	if err := registerChannelsModeration(logger, discord, config.ModeratedChannels); err != nil {
+	if err := registerChannelsModeration(logger, discord, config.AllModeratedChannels()); err != nil {
 		return fmt.Errorf("failed to register channels for moderation: %w", err)
 	}
 
@@ -46,12 +79,23 @@ func Run(logger *zap.Logger, config *Config) error {
 	defer appCtxCancel()
 	go bot.CacheRoles(appCtx, logger, discord)
 	go bot.ClearCachedWipedMessageIDs(appCtx, logger)
+	go bot.PruneMessageStore(appCtx, logger.Named("MessageStore"))
+	go bot.PruneAttachments(appCtx, logger.Named("Attachments"))
+	go bot.WatchConfig(appCtx, logger.Named("ConfigReload"), discord, configPath)
+	go bot.ScheduleRetention(appCtx, logger.Named("Retention"), discord)
+	go bot.PruneMessageWindows(appCtx, logger.Named("MessageWindows"))
 
 	// Wait until bot is ready
 	if err := bot.WaitUntilReady(ctx); err != nil {
 		return fmt.Errorf("bot is not ready until: %w", err)
 	}
 
+	if err := registerApplicationCommands(logger.Named("Commands"), discord, bot); err != nil {
+		return fmt.Errorf("failed to register application commands: %w", err)
+	}
+
+	resumeWipeJobs(logger.Named("Command.wipe"), discord, bot)
+
 	// keep bot running until there is NO os interruption (ctrl + C)
 	logger.Info("Bot running....")
 	c := make(chan os.Signal, 1)
@@ -79,19 +123,109 @@ func registerChannelsModeration(logger *zap.Logger, discord *discordgo.Session,
 	return nil
 }
 
-func newMessageHandler(logger *zap.Logger, bot *DiscordBot, config Config) interface{} {
+func newMessageHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
 	return func(discord *discordgo.Session, message *discordgo.MessageCreate) {
-		reportSuspiciousMessage(logger.Named("Moderation.ReportSuspiciousMessage"), message, discord, bot, config.Features.SuspiciousMessage, config.ReportChannel)
+		guildConfig := bot.Config().ForGuild(message.GuildID)
+
+		bot.StoreMessage(logger.Named("MessageStore"), message.Message)
+		bot.RecordAuthor(logger.Named("MessageStore"), message.Message)
+
+		// Capture is marked before the handler returns, so the report of the message deleted right away waits for it
+		if len(message.Attachments) > 0 {
+			captureDone := bot.attachmentCaptures.Start(message.ID)
+			go func() {
+				defer captureDone()
+				captureAttachments(logger.Named("Moderation.CaptureAttachments"), message.Message, bot, guildConfig.Features.ReportDeletedMessages.Attachments)
+			}()
+		}
+
+		// Restored messages were already moderated when they were posted for the first time
+		if message.WebhookID != "" && bot.restoreWebhooks.Contains(message.WebhookID) {
+			return
+		}
 
-		deleteInviteLinks(logger.Named("Moderation.DeleteInviteLinks"), message, discord, bot, config.Features.DeleteInviteLinks)
-		commandWipe(logger.Named("Command.Wipe"), message, discord, bot, config.Commands.Wipe, config.ReportChannel)
+		moderateMessage(logger, message.Message, discord, bot, guildConfig)
 	}
 }
 
-func deleteMessageHandler(logger *zap.Logger, config Config, bot *DiscordBot) interface{} {
+func deleteMessageHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
 	return func(discord *discordgo.Session, message *discordgo.MessageDelete) {
-		reportDeletedMessage(logger.Named("Moderation.ReportDeletedMessage"), message, discord, config.Features.ReportDeletedMessages, bot, config.ReportChannel)
+		guildConfig := bot.Config().ForGuild(message.GuildID)
+
+		reportDeletedMessage(logger.Named("Moderation.ReportDeletedMessage"), message, discord, guildConfig.Features.ReportDeletedMessages, bot, guildConfig.ReportChannel)
+	}
+}
+
+func updateMessageHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
+	return func(discord *discordgo.Session, message *discordgo.MessageUpdate) {
+		if message.Message == nil {
+			return
+		}
+
+		// BeforeUpdate is empty when message is not in the discord state anymore
+		before := message.BeforeUpdate
+		if before == nil {
+			stored, err := bot.messages.Get(message.ChannelID, message.ID)
+			if err == nil {
+				before = stored
+			}
+		}
+		after := mergeMessageUpdate(before, message.Message)
+		guildConfig := bot.Config().ForGuild(after.GuildID)
+
+		bot.StoreMessage(logger.Named("MessageStore"), after)
+
+		reportEditedMessage(logger.Named("Moderation.ReportEditedMessage"), before, after, discord, guildConfig.Features.ReportEditedMessages, bot, guildConfig.ReportChannel)
+
+		// Run the moderation again only when the text changed, otherwise the same message is reported twice.
+		// Discord adds link previews to the message with the update, they are moderated only when they change the result.
+		switch {
+		case before == nil || before.Content != after.Content:
+			moderateMessage(logger, after, discord, bot, guildConfig)
+		case embedsText(before) != embedsText(after) && embedsChangeModeration(logger, before, after, bot, guildConfig):
+			moderateMessage(logger, after, discord, bot, guildConfig)
+		}
+	}
+}
+
+// moderateMessage runs all the moderation features on the new or edited message
+func moderateMessage(logger *zap.Logger, message *discordgo.Message, discord *discordgo.Session, bot *DiscordBot, config ConfigGuild) {
+	// Deleted message was already reported, other features would only fail to delete it again
+	if reportSuspiciousMessage(logger.Named("Moderation.ReportSuspiciousMessage"), message, discord, bot, config.Features.SuspiciousMessage, config.ReportChannel) {
+		return
 	}
+
+	deleteInviteLinks(logger.Named("Moderation.DeleteInviteLinks"), message, discord, bot, config.Features.DeleteInviteLinks, config.ReportChannel)
+}
+
+// embedsChangeModeration reports whether the embeds added to the message match new keywords or rules,
+// increase the score or contain new invitations, so the message moderated before has to be moderated again
+func embedsChangeModeration(logger *zap.Logger, before, after *discordgo.Message, bot *DiscordBot, config ConfigGuild) bool {
+	if suspicious := config.Features.SuspiciousMessage; suspicious.Enabled {
+		beforeText, afterText := messageText(before), messageText(after)
+		beforeScore := scoreMessage(logger, before, beforeText, normalizeText(beforeText), bot, suspicious)
+		afterScore := scoreMessage(logger, after, afterText, normalizeText(afterText), bot, suspicious)
+		if afterScore.Total > beforeScore.Total {
+			return true
+		}
+
+		for _, keyword := range afterScore.Keywords {
+			if !slices.Contains(beforeScore.Keywords, keyword) {
+				return true
+			}
+		}
+	}
+
+	if config.Features.DeleteInviteLinks.Enabled {
+		beforeCodes := findInviteCodes(messageText(before))
+		for _, code := range findInviteCodes(messageText(after)) {
+			if !slices.Contains(beforeCodes, code) {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 func readyHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
@@ -114,14 +248,21 @@ func readyHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
 	}
 }
 
-func cachedUser(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, UserId string) (*ServerUser, error) {
-	userDetails := bot.CachedUser(UserID(UserId))
+// cachedUser returns details of the user in the given guild. When guild is unknown,
+// the first guild the user belongs to is used.
+func cachedUser(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, guildId string, UserId string) (*ServerUser, error) {
+	userDetails := bot.CachedUser(guildId, UserID(UserId))
 
 	if userDetails != nil {
 		return userDetails, nil
 	}
 
-	for _, guildId := range bot.GuildsIDs() {
+	guildsIds := bot.GuildsIDs()
+	if guildId != "" {
+		guildsIds = []string{guildId}
+	}
+
+	for _, guildId := range guildsIds {
 		user, err := discord.GuildMember(
 			guildId,
 			UserId,
@@ -149,7 +290,7 @@ func cachedUser(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot,
 			Roles:    roles,
 		}
 
-		bot.AddCachedUser(UserID(UserId), userDetails)
+		bot.AddCachedUser(guildId, UserID(UserId), userDetails)
 
 		return &userDetails, nil
 	}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"os"
 	"regexp"
 	"slices"
 	"strings"
@@ -12,9 +14,9 @@ import (
 	"go.uber.org/zap"
 )
 
-func isUserWhitelisted(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, whiteListerRoles []string, authorId string) bool {
+func isUserWhitelisted(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, whiteListerRoles []string, guildId string, authorId string) bool {
 	if len(whiteListerRoles) > 0 {
-		userDetails, err := cachedUser(logger, discord, bot, authorId)
+		userDetails, err := cachedUser(logger, discord, bot, guildId, authorId)
 		if err != nil {
 			logger.Sugar().Warnf("failed to get cached user: %s", err.Error())
 			return false
@@ -29,77 +31,148 @@ func isUserWhitelisted(logger *zap.Logger, discord *discordgo.Session, bot *Disc
 	return false
 }
 
+// messageText returns the message content together with the text of its embeds,
+// discord adds embeds for links after the message is posted.
+func messageText(message *discordgo.Message) string {
+	return strings.TrimSpace(message.Content + "\n" + embedsText(message))
+}
+
+// embedsText returns URLs, titles and descriptions of the message embeds, e.g. link previews
+func embedsText(message *discordgo.Message) string {
+	parts := []string{}
+
+	for _, embed := range message.Embeds {
+		if embed == nil {
+			continue
+		}
+
+		parts = append(parts, embed.URL, embed.Title, embed.Description)
+	}
+
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// reportSuspiciousMessage reports the message with the score above the threshold, it returns true when the message was deleted
 func reportSuspiciousMessage(
 	logger *zap.Logger,
-	message *discordgo.MessageCreate,
+	message *discordgo.Message,
 	discord *discordgo.Session,
 	bot *DiscordBot,
 	config ConfigSuspiciousMessage,
 	reportChannel string,
-) {
+) bool {
 	if !config.Enabled {
-		return
+		return false
 	}
 
-	if !bot.IsModeratedChannel(message.ChannelID) {
-		return
+	if !bot.IsModeratedChannel(message.GuildID, message.ChannelID) {
+		return false
 	}
 
 	// Ignore messages from bot itself
 	if message.Author != nil && message.Author.ID == discord.State.User.ID {
-		return
+		return false
 	}
 
-	if message.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.Author.ID) {
+	if message.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.GuildID, message.Author.ID) {
 		logger.Sugar().Debugf(
 			"User %s(%s) has whitelisted role, message does not need to be reported",
 			message.Author.Username,
 			message.Author.ID,
 		)
-		return
+		return false
+	}
+
+	if message.Author == nil {
+		logger.Sugar().Warnf("Message author for %s is not in the state", message.ID)
+		return false
 	}
 
 	// We can do nothing when message content is empty
-	if message.Content == "" {
+	text := messageText(message)
+	if text == "" {
 		logger.Sugar().Warnf("cannot get message content for message id %s", message.ID)
-		return
+		return false
+	}
+
+	normalized := normalizeText(text)
+	score := scoreMessage(logger, message, text, normalized, bot, config)
+	if score.Total <= 0 || score.Total < config.Score.reportThreshold() {
+		return false
 	}
 
-	suspicious := false
-	for _, keyword := range config.Keywords {
-		if strings.Contains(strings.ToLower(message.Content), strings.ToLower(keyword)) {
-			suspicious = true
-			break
+	title := "Suspicious message on the server"
+	if message.EditedTimestamp != nil {
+		title = "Suspicious edited message on the server"
+	}
+	color := ReportColorSuspicious
+	deleted := false
+
+	if config.Score.DeleteThreshold > 0 && score.Total >= config.Score.DeleteThreshold {
+		// Deleted message is reported here, it does not need to be reported again as the deleted one
+		bot.wipedMessages.Add(message.ID, true)
+
+		if err := discord.ChannelMessageDelete(message.ChannelID, message.ID); err != nil {
+			logger.Error("failed to delete suspicious message", zap.String("message", message.ID), zap.Error(err))
+		} else {
+			title = "Suspicious message deleted"
+			color = ReportColorDeleted
+			deleted = true
 		}
 	}
 
-	if !suspicious {
-		return
+	report := Report{
+		Title:   title,
+		Color:   color,
+		Feature: "suspicious_messages",
+		Rule:    strings.Join(score.Keywords, ", "),
+		Author:  message.Author,
+		Message: message,
+		Content: text,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Score",
+				Value:  fmt.Sprintf("%.2f (report at %.2f, delete at %s)", score.Total, config.Score.reportThreshold(), deleteThresholdText(config.Score)),
+				Inline: true,
+			},
+			{Name: "Score breakdown", Value: score.Breakdown()},
+		},
 	}
 
-	logMessage := fmt.Sprintf("Suspicious message on the server\n================================\nAuthor: <@%s>\nChannel: <#%s>\nMessage: ```%s```",
-		message.Author.ID,
-		message.ChannelID,
-		message.Content,
-	)
-	logger.Info(logMessage)
+	// Raw text is in the report content, the normalized one shows what was matched in the obfuscated text
+	if normalized != text {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{
+			Name:  "Normalized text",
+			Value: codeBlock(normalized, embedFieldValueLimit),
+		})
+	}
+
+	sendReport(logger, discord, reportChannel, report)
+
+	return deleted
+}
+
+func deleteThresholdText(config ConfigScore) string {
+	if config.DeleteThreshold <= 0 {
+		return "never"
+	}
 
-	discord.ChannelMessageSend(reportChannel, logMessage)
+	return fmt.Sprintf("%.2f", config.DeleteThreshold)
 }
 
 func deleteInviteLinks(
 	logger *zap.Logger,
-	message *discordgo.MessageCreate,
+	message *discordgo.Message,
 	discord *discordgo.Session,
 	bot *DiscordBot,
 	config ConfigDeleteInviteLinks,
-	// reportChannel string,
+	reportChannel string,
 ) {
 	if !config.Enabled {
 		return
 	}
 
-	if !bot.IsModeratedChannel(message.ChannelID) {
+	if !bot.IsModeratedChannel(message.GuildID, message.ChannelID) {
 		return
 	}
 
@@ -108,7 +181,7 @@ func deleteInviteLinks(
 		return
 	}
 
-	if message.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.Author.ID) {
+	if message.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.GuildID, message.Author.ID) {
 		logger.Sugar().Debugf(
 			"User %s(%s) has whitelisted role, message does not need to be reported",
 			message.Author.Username,
@@ -117,7 +190,41 @@ func deleteInviteLinks(
 		return
 	}
 
-	if !shouldMessageBeDeleted(logger, message.Content) {
+	if message.Author == nil {
+		logger.Sugar().Warnf("Message author for %s is not in the state", message.ID)
+		return
+	}
+
+	text := messageText(message)
+	window := bot.messageWindows.Add(message, text, config.window(), config.windowSize())
+
+	// Single message is checked first, the invitation may be also split into several messages
+	messageIDs := []string{message.ID}
+	codes, chain := messageInviteCodes(logger, text, bot.LinkResolver())
+	if len(codes) < 1 && len(chain.Hops) < 1 && len(window) > 1 {
+		codes, chain = windowInviteCodes(logger, window, bot.LinkResolver())
+		if len(codes) > 0 {
+			text = joinWindowText(window)
+			messageIDs = []string{}
+			for _, recent := range window {
+				messageIDs = append(messageIDs, recent.ID)
+			}
+			logger.Sugar().Infof("Invitation split into %d messages by %s(%s)", len(messageIDs), message.Author.Username, message.Author.ID)
+		}
+	}
+
+	// The complete invitation or link must not be joined with the next messages, e.g. "discord.gg/abc" and "welcome"
+	// would become "discord.gg/abcwelcome". Broken links are kept, they may be the first part of the split link.
+	if len(codes) > 0 || (len(chain.Hops) > 0 && chain.Err == nil) {
+		bot.messageWindows.Clear(message)
+	}
+	if len(codes) < 1 {
+		return
+	}
+
+	invites, unresolved := resolveInvites(discord, bot.invites, codes)
+	if allInvitesAllowed(invites, unresolved, message.GuildID, config.AllowedGuilds) {
+		logger.Sugar().Debugf("Message %s contains invitations only to allowed servers", message.ID)
 		return
 	}
 
@@ -130,57 +237,152 @@ func deleteInviteLinks(
 		logger.Sugar().Error("failed to send warn message after posting server invitation: %s", err.Error())
 	}
 
-	if err := discord.ChannelMessageDelete(message.ChannelID, message.ID); err != nil {
+	// Deleted messages are reported below, they do not need to be reported again as the deleted ones
+	for _, messageID := range messageIDs {
+		bot.wipedMessages.Add(messageID, true)
+	}
+
+	title := "Invitation deleted"
+	if len(messageIDs) > 1 {
+		if err := discord.ChannelMessagesBulkDelete(message.ChannelID, messageIDs); err != nil {
+			logger.Sugar().Errorf("failed to delete %d messages with split server invitation: %s", len(messageIDs), err.Error())
+			title = "Failed to delete invitation"
+		}
+	} else if err := discord.ChannelMessageDelete(message.ChannelID, message.ID); err != nil {
 		logger.Sugar().Error("failed to send delete invitation with posted server invitation: %s", err.Error())
+		title = "Failed to delete invitation"
+	}
+
+	report := Report{
+		Title:   title,
+		Color:   ReportColorDeleted,
+		Feature: "delete_invite_links",
+		Author:  message.Author,
+		Message: message,
+		Content: text,
+	}
+	if len(messageIDs) > 1 {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: "Messages", Value: fmt.Sprintf("Invitation split into %d messages", len(messageIDs))})
 	}
+	if normalized := normalizeText(text); normalized != text {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{
+			Name:  "Normalized text",
+			Value: codeBlock(normalized, embedFieldValueLimit),
+		})
+	}
+	if len(chain.Hops) > 0 {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: "Link chain", Value: chain.String()})
+	}
+	for _, invite := range invites {
+		name := fmt.Sprintf("Invitation %s", invite.Code)
+		if invite.HighRisk(config.newServerAge()) {
+			name = fmt.Sprintf("High risk invitation %s", invite.Code)
+		}
+		if invite.Allowed(message.GuildID, config.AllowedGuilds) {
+			name = fmt.Sprintf("Allowed invitation %s", invite.Code)
+		}
+
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: name, Value: invite.Details(config.newServerAge())})
+	}
+	if len(unresolved) > 0 {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("Not resolved invitations (%d)", len(unresolved)),
+			// Short list keeps the whole report below the embed size limit
+			Value: truncateText(escapeMarkdown(strings.Join(unresolved, ", ")), 256),
+		})
+	}
+
+	sendReport(logger, discord, reportChannel, report)
 }
 
+// Example matches:
+//   - discord.com/invite\ZnZ3nxZMuq
+//   - discordapp.com/invite\ZnZ3nxZMuq
+var inviteRegex = regexp.MustCompile(`(?i)(https?:\/\/)?(www\.)?((discord(app)?\.com[/\\]invite)|(discord\.gg))[/\\]\w+`)
+
+// isDiscordInvitation checks the message and its normalized text, so obfuscated invitations are found too
 func isDiscordInvitation(message string) bool {
-	// Example matches:
-	//	- discord.com/invite\ZnZ3nxZMuq
-	//	- discordapp.com/invite\ZnZ3nxZMuq
-	inviteRegex := regexp.MustCompile(`(https?:\/\/)?(www\.)?((discord(app)?\.com[/\\]invite)|(discord\.gg))[/\\]\w+`)
-	return inviteRegex.MatchString(message)
+	return inviteRegex.MatchString(message) || inviteRegex.MatchString(normalizeText(message))
 }
 
-func shouldMessageBeDeleted(logger *zap.Logger, message string) bool {
-	if isDiscordInvitation(message) {
-		return true
+// Some of the spammers send custom domains that returns only 301 Location: discord.com/invite/xxxx
+// or pages with meta refresh, javascript redirect or just the invitation link
+// Examples:
+//   - https:/%20@@dis.army/chat/21312
+var urlRegex = regexp.MustCompile(`(https?):/\/?([^\s]+)`)
+
+// messageInviteCodes returns codes of the invitations in the message. When there is no invitation in the text,
+// the link in the message is followed and the chain of the visited URLs is returned too.
+func messageInviteCodes(logger *zap.Logger, message string, resolver *LinkResolver) ([]string, LinkChain) {
+	if codes := findInviteCodes(message); len(codes) > 0 {
+		return codes, LinkChain{}
 	}
 
-	// Some of the spammers send custom domains that returns only 301 Location: discord.com/invite/xxxx
-	// Examples:
-	//	- https:/%20@@dis.army/chat/21312
-	urlRegex := regexp.MustCompile(`(https?):/\/?([^\s]+)`)
 	foundUrl := urlRegex.FindStringSubmatch(message)
-	if len(foundUrl) > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
+	if len(foundUrl) < 1 {
+		foundUrl = urlRegex.FindStringSubmatch(normalizeText(message))
+	}
+	if len(foundUrl) < 1 {
+		return nil, LinkChain{}
+	}
 
-		// We cannot just throw the found link into the http.Get function because scammers often put malformed chars there
-		req, err := BuildInvitationCheckHttpRequest(ctx, fmt.Sprintf("%s://%s", foundUrl[1], foundUrl[2]))
-		if err != nil {
-			logger.Error(
-				fmt.Sprintf("failed to build request to check invitation link for URL %s", foundUrl[0]),
-				zap.Error(err),
-			)
+	return followLink(logger, foundUrl, resolver)
+}
+
+// windowInviteCodes returns codes of the invitations split into several messages of the window. Only invitations
+// and links which start in one message and end in the other one are used, the rest is checked in the single message.
+func windowInviteCodes(logger *zap.Logger, window []WindowMessage, resolver *LinkResolver) ([]string, LinkChain) {
+	raw, normalized := []string{}, []string{}
+	for _, message := range window {
+		// Message with its own invitation was already checked, the split invitation starts after it
+		if len(findInviteCodes(message.Text)) > 0 {
+			raw, normalized = []string{}, []string{}
+			continue
 		}
-		httpClient := DefaultHttpClient(5 * time.Second)
-		resp, err := httpClient.Do(req)
 
-		if err != nil {
-			logger.Sugar().Infof("checking if message with link(%s) should be deleted, but cannot open page: %s", foundUrl[0], err.Error())
-			return false
+		raw = append(raw, message.Text)
+		normalized = append(normalized, normalizeText(message.Text))
+	}
+	candidates := []windowText{newWindowText(raw), newWindowText(normalized)}
+
+	codes := []string{}
+	for _, candidate := range candidates {
+		for _, match := range inviteCodeRegex.FindAllStringSubmatchIndex(candidate.text, -1) {
+			code := candidate.text[match[2]:match[3]]
+			if candidate.spans(match[0], match[1]) && !slices.Contains(codes, code) {
+				codes = append(codes, code)
+			}
 		}
+	}
+	if len(codes) > 0 {
+		return codes, LinkChain{}
+	}
+
+	for _, candidate := range candidates {
+		for _, match := range urlRegex.FindAllStringSubmatchIndex(candidate.text, -1) {
+			if !candidate.spans(match[0], match[1]) {
+				continue
+			}
 
-		// Follow all the redirects, etc and then check the latest Request URL
-		latestUrl := resp.Request.URL.String()
-		if isDiscordInvitation(latestUrl) {
-			return true
+			foundUrl := []string{candidate.text[match[0]:match[1]], candidate.text[match[2]:match[3]], candidate.text[match[4]:match[5]]}
+			return followLink(logger, foundUrl, resolver)
 		}
 	}
 
-	return false
+	return nil, LinkChain{}
+}
+
+// followLink resolves the link found by urlRegex and returns codes of the invitations it leads to
+func followLink(logger *zap.Logger, foundUrl []string, resolver *LinkResolver) ([]string, LinkChain) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLinkResolverTimeout)
+	defer cancel()
+
+	chain := resolver.Resolve(ctx, fmt.Sprintf("%s://%s", foundUrl[1], foundUrl[2]))
+	if chain.Err != nil {
+		logger.Sugar().Infof("checking if message with link(%s) should be deleted, but cannot follow it: %s", foundUrl[0], chain.Err.Error())
+	}
+
+	return chain.InviteCodes, chain
 }
 
 func reportDeletedMessage(
@@ -195,7 +397,7 @@ func reportDeletedMessage(
 		return
 	}
 
-	if !bot.IsModeratedChannel(message.ChannelID) {
+	if !bot.IsModeratedChannel(message.GuildID, message.ChannelID) {
 		return
 	}
 
@@ -208,33 +410,197 @@ func reportDeletedMessage(
 		return
 	}
 
-	// If BeforeDelete is empty, there is nothing more We can do, as We lost track of this message.
-	// Sometimes message is deleted too fast to process it
-	if message.BeforeDelete == nil {
-		logger.Sugar().Warnf("Message %s is not in the state bot state", message.ID)
-		return
+	// If BeforeDelete is empty, the message is not in the discord state anymore.
+	// Sometimes message is deleted too fast to process it or it was posted before the bot restart
+	deleted := message.BeforeDelete
+	if deleted == nil {
+		stored, err := bot.messages.Get(message.ChannelID, message.ID)
+		if err != nil {
+			logger.Sugar().Warnf("Message %s is not in the state bot state nor in the message store: %s", message.ID, err.Error())
+			return
+		}
+
+		deleted = stored
 	}
 
-	if message.BeforeDelete.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.BeforeDelete.Author.ID) {
+	if deleted.Author != nil && isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, message.GuildID, deleted.Author.ID) {
 		logger.Sugar().Debugf(
 			"User %s(%s) has whitelisted role, message does not need to be reported",
-			message.BeforeDelete.Author.Username,
-			message.BeforeDelete.Author.ID,
+			deleted.Author.Username,
+			deleted.Author.ID,
 		)
 		return
 	}
 
-	if message.BeforeDelete.Author == nil {
+	if deleted.Author == nil {
 		logger.Sugar().Warnf("Message author for %s is not in the state", message.ID)
 		return
 	}
 
-	logMessage := fmt.Sprintf("New deleted message on the server\n=================================\nAuthor: <@%s>\nChannel: <#%s>\nMessage: ```%s```",
-		message.BeforeDelete.Author.ID,
-		message.BeforeDelete.ChannelID,
-		message.BeforeDelete.Content,
-	)
-	logger.Info(logMessage)
+	report := Report{
+		Title:   "New deleted message on the server",
+		Color:   ReportColorDeleted,
+		Feature: "report_deleted_messages",
+		Author:  deleted.Author,
+		Message: deleted,
+		Content: deleted.Content,
+	}
+
+	if len(deleted.Attachments) > 0 {
+		names := []string{}
+		for _, attachment := range deleted.Attachments {
+			names = append(names, escapeMarkdown(attachment.Filename))
+		}
+
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Attachments (%d)", len(deleted.Attachments)),
+			Value: strings.Join(names, "\n"),
+		})
+	}
+
+	if bot.attachments != nil {
+		if !bot.attachmentCaptures.Wait(deleted.ID, AttachmentsCaptureWait) {
+			logger.Warn("attachments are still being captured, the report is sent without them", zap.String("message", deleted.ID))
+		}
+
+		blobs, err := bot.attachments.List(deleted.ID)
+		if err != nil {
+			logger.Warn("failed to list captured attachments", zap.String("message", deleted.ID), zap.Error(err))
+		}
+
+		for _, blob := range blobs {
+			file, err := os.Open(blob.Path)
+			if err != nil {
+				logger.Warn("failed to open captured attachment", zap.String("path", blob.Path), zap.Error(err))
+				continue
+			}
+			defer file.Close()
+
+			report.Files = append(report.Files, &discordgo.File{
+				Name:   blob.Name,
+				Reader: file,
+			})
+		}
+	}
+
+	sendReport(logger, discord, reportChannel, report)
+}
+
+// captureAttachments downloads attachments of the message in the moderated channel, so they can be
+// attached to the report when the message is deleted. Discord removes files of the deleted messages.
+func captureAttachments(
+	logger *zap.Logger,
+	message *discordgo.Message,
+	bot *DiscordBot,
+	config ConfigAttachments,
+) {
+	if !config.Enabled || bot.attachments == nil {
+		return
+	}
+
+	if len(message.Attachments) < 1 || !bot.IsModeratedChannel(message.GuildID, message.ChannelID) {
+		return
+	}
+
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultAttachmentsMaxSize
+	}
+	maxCount := config.MaxCount
+	if maxCount <= 0 {
+		maxCount = DefaultAttachmentsMaxCount
+	}
+
+	httpClient := DefaultHttpClient(30 * time.Second)
+	captured := 0
+	for _, attachment := range message.Attachments {
+		if captured >= maxCount {
+			logger.Sugar().Debugf("Message %s has more than %d attachments, skipping the rest", message.ID, maxCount)
+			return
+		}
+
+		if int64(attachment.Size) > maxSize {
+			logger.Sugar().Debugf("Attachment %s of message %s is too large: %d bytes", attachment.Filename, message.ID, attachment.Size)
+			continue
+		}
+
+		if err := func() error {
+			resp, err := httpClient.Get(attachment.URL)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			}
+
+			return bot.attachments.Put(message.ID, fmt.Sprintf("%s-%s", attachment.ID, attachment.Filename), resp.Body, maxSize)
+		}(); err != nil {
+			logger.Warn("failed to capture attachment", zap.String("message", message.ID), zap.String("attachment", attachment.Filename), zap.Error(err))
+			continue
+		}
+
+		captured++
+	}
+}
+
+func reportEditedMessage(
+	logger *zap.Logger,
+	before *discordgo.Message,
+	after *discordgo.Message,
+	discord *discordgo.Session,
+	config ConfigReportEditedMessages,
+	bot *DiscordBot,
+	reportChannel string,
+) {
+	if !config.Enabled {
+		return
+	}
+
+	if !bot.IsModeratedChannel(after.GuildID, after.ChannelID) {
+		return
+	}
+
+	if after.Author != nil && after.Author.ID == discord.State.User.ID {
+		return
+	}
+
+	if before == nil {
+		logger.Sugar().Warnf("Message %s is not in the state bot state nor in the message store", after.ID)
+		return
+	}
+
+	// Discord also sends update when it adds embeds for links, content is the same then
+	if before.Content == after.Content {
+		return
+	}
+
+	if after.Author == nil {
+		logger.Sugar().Warnf("Message author for %s is not in the state", after.ID)
+		return
+	}
+
+	if isUserWhitelisted(logger, discord, bot, config.WhiteListedRoles, after.GuildID, after.Author.ID) {
+		logger.Sugar().Debugf(
+			"User %s(%s) has whitelisted role, message does not need to be reported",
+			after.Author.Username,
+			after.Author.ID,
+		)
+		return
+	}
 
-	discord.ChannelMessageSend(reportChannel, logMessage)
+	diff := DiffWords(before.Content, after.Content)
+
+	sendReport(logger, discord, reportChannel, Report{
+		Title:   "Edited message on the server",
+		Color:   ReportColorEdited,
+		Feature: "report_edited_messages",
+		Author:  after.Author,
+		Message: after,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Before", Value: nonEmpty(HighlightDiff(diff, DiffRemoved, embedFieldValueLimit)), Inline: true},
+			{Name: "After", Value: nonEmpty(HighlightDiff(diff, DiffAdded, embedFieldValueLimit)), Inline: true},
+		},
+	})
 }
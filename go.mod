@@ -5,7 +5,10 @@ go 1.22.3
 require (
 	github.com/BurntSushi/toml v1.4.0
 	github.com/bwmarrin/discordgo v0.28.1
+	github.com/robfig/cron/v3 v3.0.1
+	go.etcd.io/bbolt v1.3.11
 	go.uber.org/zap v1.27.0
+	golang.org/x/text v0.21.0
 	golang.org/x/time v0.8.0
 )
 
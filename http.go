@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"time"
 )
@@ -16,6 +17,27 @@ func DefaultHttpClient(timeout time.Duration) *http.Client {
 	}
 }
 
+// LinkCheckHttpClient is used to open links posted by users, it connects only to addresses allowed by the filter.
+// The proxy is not used, because the filter would check the address of the proxy instead of the server.
+func LinkCheckHttpClient(timeout time.Duration, filter *IPFilter) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout / 3,
+		Control: filter.Control,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+			TLSHandshakeTimeout:   timeout / 3,
+			ResponseHeaderTimeout: timeout - (timeout / 3),
+		},
+	}
+}
+
 // Prepare URL because sometimes scammers are using instagram, facebook,
 // twitter, vk, etc... as proxy and they verify requests if it is sent by bots
 func BuildInvitationCheckHttpRequest(ctx context.Context, url string) (*http.Request, error) {
@@ -27,7 +49,8 @@ func BuildInvitationCheckHttpRequest(ctx context.Context, url string) (*http.Req
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0")
 	req.Header.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 	req.Header.Add("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Add("Accept-Encoding", "gzip, deflate, br, zstd")
+	// Only encodings the link resolver can decompress
+	req.Header.Add("Accept-Encoding", "gzip, deflate")
 	req.Header.Add("DNT", "1")
 	req.Header.Add("Upgrade-Insecure-Requests", "1")
 	req.Header.Add("Connection", "keep-alive")
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// ApplicationCommand is the guild slash command. Its definition depends on the guild config,
+// so it can be renamed or disabled per guild.
+type ApplicationCommand struct {
+	// Definition returns nil when the command is disabled in the guild
+	Definition       func(config ConfigGuild) *discordgo.ApplicationCommand
+	WhitelistedRoles func(config ConfigGuild) []string
+	ActiveChannels   func(config ConfigGuild) []string
+	Handler          func(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, config ConfigGuild)
+}
+
+// ComponentHandler handles interactions with message components, e.g. buttons. Custom ID of
+// the component is split by ":", the first part selects the handler, the rest is passed as args.
+type ComponentHandler func(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, args []string)
+
+func componentHandlers() map[string]ComponentHandler {
+	return map[string]ComponentHandler{
+		wipeComponentPrefix:       wipeComponentHandler,
+		wipeResumeComponentPrefix: wipeResumeComponentHandler,
+	}
+}
+
+func applicationCommands() []ApplicationCommand {
+	return []ApplicationCommand{
+		wipeApplicationCommand(),
+		restoreApplicationCommand(),
+	}
+}
+
+// registerApplicationCommands overwrites slash commands in all the guilds with commands enabled in the config
+func registerApplicationCommands(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot) error {
+	config := bot.Config()
+
+	for _, guildID := range bot.GuildsIDs() {
+		definitions := []*discordgo.ApplicationCommand{}
+		for _, command := range applicationCommands() {
+			if definition := command.Definition(config.ForGuild(guildID)); definition != nil {
+				definitions = append(definitions, definition)
+			}
+		}
+
+		if _, err := discord.ApplicationCommandBulkOverwrite(bot.ApplicationID(), guildID, definitions); err != nil {
+			return fmt.Errorf("failed to register commands in guild %s: %w", guildID, err)
+		}
+
+		logger.Sugar().Infof("registered %d commands in guild %s", len(definitions), guildID)
+	}
+
+	return nil
+}
+
+func interactionCreateHandler(logger *zap.Logger, bot *DiscordBot) interface{} {
+	return func(discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+		if interaction.Type == discordgo.InteractionMessageComponent {
+			handleComponentInteraction(logger, interaction, discord, bot)
+			return
+		}
+
+		if interaction.Type != discordgo.InteractionApplicationCommand {
+			return
+		}
+
+		if interaction.GuildID == "" || interaction.Member == nil || interaction.Member.User == nil {
+			respondEphemeral(logger, discord, interaction, "Commands can be used only in the server channels.")
+			return
+		}
+
+		config := bot.Config().ForGuild(interaction.GuildID)
+		name := interaction.ApplicationCommandData().Name
+
+		for _, command := range applicationCommands() {
+			definition := command.Definition(config)
+			if definition == nil || definition.Name != name {
+				continue
+			}
+
+			commandLogger := logger.Named(fmt.Sprintf("Command.%s", name))
+			if !slices.Contains(command.ActiveChannels(config), interaction.ChannelID) {
+				commandLogger.Sugar().Debugf("Channel(%s) has not enabled %s command", interaction.ChannelID, name)
+				respondEphemeral(logger, discord, interaction, "This command cannot be used in this channel.")
+				return
+			}
+
+			if !isUserWhitelisted(commandLogger, discord, bot, command.WhitelistedRoles(config), interaction.GuildID, interaction.Member.User.ID) {
+				commandLogger.Sugar().Debugf(
+					"User %s(%s) is not allowed to execute %s command",
+					interaction.Member.User.Username,
+					interaction.Member.User.ID,
+					name,
+				)
+				respondEphemeral(logger, discord, interaction, "You are not allowed to use this command.")
+				return
+			}
+
+			command.Handler(commandLogger, interaction, discord, bot, config)
+			return
+		}
+
+		logger.Sugar().Warnf("unknown command %s in guild %s", name, interaction.GuildID)
+		respondEphemeral(logger, discord, interaction, "This command is not available anymore.")
+	}
+}
+
+func handleComponentInteraction(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot) {
+	parts := strings.Split(interaction.MessageComponentData().CustomID, ":")
+
+	handler, found := componentHandlers()[parts[0]]
+	if !found {
+		logger.Sugar().Warnf("unknown component %s", interaction.MessageComponentData().CustomID)
+		respondEphemeral(logger, discord, interaction, "This action is not available anymore.")
+		return
+	}
+
+	handler(logger.Named(fmt.Sprintf("Component.%s", parts[0])), interaction, discord, bot, parts[1:])
+}
+
+func respondEphemeral(logger *zap.Logger, discord *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	if err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
+
+// deferEphemeral acknowledges the interaction, discord requires response within 3 seconds.
+// The response is sent later with editResponse.
+func deferEphemeral(logger *zap.Logger, discord *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		logger.Error("failed to defer interaction response", zap.Error(err))
+	}
+}
+
+func editResponse(logger *zap.Logger, discord *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	if _, err := discord.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	}); err != nil {
+		logger.Error("failed to edit interaction response", zap.Error(err))
+	}
+}
+
+// updateComponentMessage replaces content of the message with components and removes the components
+func updateComponentMessage(logger *zap.Logger, discord *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	if err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	}); err != nil {
+		logger.Error("failed to update interaction message", zap.Error(err))
+	}
+}
+
+// interactionUser returns the user who triggered the interaction
+func interactionUser(interaction *discordgo.InteractionCreate) *discordgo.User {
+	if interaction.Member != nil && interaction.Member.User != nil {
+		return interaction.Member.User
+	}
+
+	return interaction.User
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// MaxResolvedInvites limits API requests made for the single message, every request is made in the message handler
+	MaxResolvedInvites = 3
+	InviteCacheTTL     = 10 * time.Minute
+)
+
+// DefaultNewServerAge is the age of the server below which the invitation to it is marked as high risk,
+// scammers create new servers for every campaign, because old ones are reported and removed by discord
+const DefaultNewServerAge = 24 * time.Hour
+
+// inviteCodeRegex finds the code of the invitation, see inviteRegex
+var inviteCodeRegex = regexp.MustCompile(`(?i)(?:discord(?:app)?\.com[/\\]invite|discord\.gg)[/\\]([\w-]+)`)
+
+// ResolvedInvite describes the server the invitation leads to
+type ResolvedInvite struct {
+	Code        string
+	GuildID     string
+	GuildName   string
+	MemberCount int
+	CreatedAt   time.Time
+
+	// Err is set when the invitation cannot be resolved, e.g. it expired or it is invalid
+	Err error
+}
+
+func (c ConfigDeleteInviteLinks) newServerAge() time.Duration {
+	if c.NewServerAge <= 0 {
+		return DefaultNewServerAge
+	}
+
+	return c.NewServerAge
+}
+
+// findInviteCodes returns unique codes of invitations in the raw and the normalized text
+func findInviteCodes(text string) []string {
+	codes := []string{}
+	for _, candidate := range []string{text, normalizeText(text)} {
+		for _, match := range inviteCodeRegex.FindAllStringSubmatch(candidate, -1) {
+			if !slices.Contains(codes, match[1]) {
+				codes = append(codes, match[1])
+			}
+		}
+	}
+
+	return codes
+}
+
+// resolveInvites gets servers of the first MaxResolvedInvites invitations through the discord API,
+// codes of the other invitations are returned without resolving them
+func resolveInvites(discord *discordgo.Session, cache *InviteCache, codes []string) ([]ResolvedInvite, []string) {
+	resolved := []ResolvedInvite{}
+	for idx, code := range codes {
+		if idx >= MaxResolvedInvites {
+			return resolved, codes[idx:]
+		}
+
+		if invite, found := cache.Get(code); found {
+			resolved = append(resolved, invite)
+			continue
+		}
+
+		invite := resolveInvite(discord, code)
+		// Failed requests are not cached, the error may be temporary
+		if invite.Err == nil {
+			cache.Put(invite)
+		}
+		resolved = append(resolved, invite)
+	}
+
+	return resolved, nil
+}
+
+func resolveInvite(discord *discordgo.Session, code string) ResolvedInvite {
+	resolved := ResolvedInvite{Code: code}
+
+	invite, err := discord.InviteWithCounts(code)
+	if err != nil {
+		resolved.Err = fmt.Errorf("failed to resolve invitation: %w", err)
+		return resolved
+	}
+	if invite.Guild == nil {
+		resolved.Err = fmt.Errorf("invitation does not lead to a server")
+		return resolved
+	}
+
+	resolved.GuildID = invite.Guild.ID
+	resolved.GuildName = invite.Guild.Name
+	resolved.MemberCount = invite.ApproximateMemberCount
+	if createdAt, err := discordgo.SnowflakeTimestamp(invite.Guild.ID); err == nil {
+		resolved.CreatedAt = createdAt
+	}
+
+	return resolved
+}
+
+// Allowed reports whether the invitation leads to the current server or to one of the allowed servers.
+// Invitations which cannot be resolved are never allowed.
+func (i ResolvedInvite) Allowed(guildID string, allowedGuilds []string) bool {
+	if i.Err != nil {
+		return false
+	}
+
+	return i.GuildID == guildID || slices.Contains(allowedGuilds, i.GuildID)
+}
+
+// HighRisk reports whether the server of the invitation was created recently
+func (i ResolvedInvite) HighRisk(newServerAge time.Duration) bool {
+	return !i.CreatedAt.IsZero() && time.Since(i.CreatedAt) < newServerAge
+}
+
+// Details describes the server of the invitation for the report
+func (i ResolvedInvite) Details(newServerAge time.Duration) string {
+	if i.Err != nil {
+		return escapeMarkdown(i.Err.Error())
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s (%s)", escapeMarkdown(i.GuildName), i.GuildID),
+		fmt.Sprintf("Members: %d", i.MemberCount),
+	}
+	if !i.CreatedAt.IsZero() {
+		lines = append(lines, fmt.Sprintf("Created: %s", discordTimestamp(i.CreatedAt)))
+	}
+	if i.HighRisk(newServerAge) {
+		lines = append(lines, fmt.Sprintf("**High risk**: server created less than %s ago", newServerAge))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// allInvitesAllowed reports whether there is at least one invitation and all of them are allowed.
+// Invitations which were not resolved are never allowed.
+func allInvitesAllowed(invites []ResolvedInvite, unresolved []string, guildID string, allowedGuilds []string) bool {
+	if len(invites) < 1 || len(unresolved) > 0 {
+		return false
+	}
+
+	for _, invite := range invites {
+		if !invite.Allowed(guildID, allowedGuilds) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type cachedInvite struct {
+	invite     ResolvedInvite
+	validUntil time.Time
+}
+
+// InviteCache keeps resolved invitations, spammers post the same invitation many times
+type InviteCache struct {
+	mut     sync.Mutex
+	invites map[string]cachedInvite
+}
+
+func NewInviteCache() *InviteCache {
+	return &InviteCache{
+		invites: map[string]cachedInvite{},
+	}
+}
+
+func (c *InviteCache) Get(code string) (ResolvedInvite, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	cached, found := c.invites[code]
+	if !found || time.Now().After(cached.validUntil) {
+		return ResolvedInvite{}, false
+	}
+
+	return cached.invite, true
+}
+
+// Put caches the invitation for InviteCacheTTL, expired invitations are removed at the same time
+func (c *InviteCache) Put(invite ResolvedInvite) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := time.Now()
+	for code, cached := range c.invites {
+		if now.After(cached.validUntil) {
+			delete(c.invites, code)
+		}
+	}
+
+	c.invites[invite.Code] = cachedInvite{invite: invite, validUntil: now.Add(InviteCacheTTL)}
+}
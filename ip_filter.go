@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// blockedPrefixes are addresses of the bot host and its internal network. Links posted by users must not be
+// able to reach them, e.g. http://127.0.0.1:8080 or the cloud metadata service at http://169.254.169.254
+var blockedPrefixes = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "unspecified"},
+	{netip.MustParsePrefix("10.0.0.0/8"), "private"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "carrier-grade NAT"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "loopback"},
+	{netip.MustParsePrefix("169.254.169.254/32"), "cloud metadata"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "link-local"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "private"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignments"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "private"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "benchmarking"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "multicast"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved"},
+	{netip.MustParsePrefix("::/128"), "unspecified"},
+	{netip.MustParsePrefix("::1/128"), "loopback"},
+	{netip.MustParsePrefix("fd00:ec2::254/128"), "cloud metadata"},
+	{netip.MustParsePrefix("fc00::/7"), "private"},
+	{netip.MustParsePrefix("fe80::/10"), "link-local"},
+	{netip.MustParsePrefix("ff00::/8"), "multicast"},
+	// The position of IPv4 in the local-use NAT64 address depends on the network, so the whole range is blocked
+	{netip.MustParsePrefix("64:ff9b:1::/48"), "local-use NAT64"},
+}
+
+// IPv6 ranges with the embedded IPv4 address, see embeddedIPv4
+var (
+	nat64Prefix          = netip.MustParsePrefix("64:ff9b::/96")
+	ipv4CompatiblePrefix = netip.MustParsePrefix("::/96")
+	sixToFourPrefix      = netip.MustParsePrefix("2002::/16")
+	teredoPrefix         = netip.MustParsePrefix("2001::/32")
+)
+
+// IPFilter decides which addresses the link resolver can connect to. Denied prefixes are checked first,
+// then allowed ones, so internal addresses can be allowed or more addresses can be denied by the config.
+type IPFilter struct {
+	allowed []netip.Prefix
+	denied  []netip.Prefix
+}
+
+func NewIPFilter(allowed, denied []string) (*IPFilter, error) {
+	filter := &IPFilter{}
+
+	var err error
+	if filter.allowed, err = parsePrefixes(allowed); err != nil {
+		return nil, err
+	}
+	if filter.denied, err = parsePrefixes(denied); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Check returns the error when the connection to the address is not allowed. IPv4 address embedded
+// in the IPv6 one, e.g. NAT64 64:ff9b::7f00:1 or 6to4 2002:7f00:1::, is checked too, because
+// on the host with NAT64 or 6to4 the connection reaches this IPv4 address.
+func (f *IPFilter) Check(addr netip.Addr) error {
+	// IPv4 address mapped to IPv6, e.g. ::ffff:127.0.0.1, is checked as IPv4. Zoned addresses never match the prefix.
+	addr = addr.Unmap().WithZone("")
+
+	if err := f.check(addr); err != nil {
+		return err
+	}
+
+	if embedded, found := embeddedIPv4(addr); found {
+		if err := f.check(embedded); err != nil {
+			return fmt.Errorf("address %s embeds IPv4: %w", addr, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *IPFilter) check(addr netip.Addr) error {
+	for _, prefix := range f.denied {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("address %s is denied by %s", addr, prefix)
+		}
+	}
+
+	for _, prefix := range f.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+
+	for _, blocked := range blockedPrefixes {
+		if blocked.prefix.Contains(addr) {
+			return fmt.Errorf("address %s is %s", addr, blocked.name)
+		}
+	}
+
+	return nil
+}
+
+// embeddedIPv4 returns the IPv4 address translated from the IPv6 one by the network
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() {
+		return netip.Addr{}, false
+	}
+
+	bytes := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr), ipv4CompatiblePrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(bytes[12:16])), true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(bytes[2:6])), true
+	case teredoPrefix.Contains(addr):
+		// Teredo client address is stored inverted in the last 32 bits
+		return netip.AddrFrom4([4]byte{^bytes[12], ^bytes[13], ^bytes[14], ^bytes[15]}), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// Control is called by the dialer after the host name is resolved and before the connection is made,
+// so every connection, also after the redirect, is checked against the real address of the server
+func (f *IPFilter) Control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	if err := f.Check(addr); err != nil {
+		return fmt.Errorf("connection not allowed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIPFilterCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		addr    string
+		wantErr bool
+	}{
+		{"public IPv4", nil, nil, "8.8.8.8", false},
+		{"public IPv6", nil, nil, "2606:4700::1", false},
+		{"loopback", nil, nil, "127.0.0.1", true},
+		{"loopback IPv6", nil, nil, "::1", true},
+		{"unspecified", nil, nil, "0.0.0.0", true},
+		{"private", nil, nil, "192.168.1.1", true},
+		{"private 10", nil, nil, "10.1.2.3", true},
+		{"private 172", nil, nil, "172.16.0.1", true},
+		{"carrier-grade NAT", nil, nil, "100.64.1.1", true},
+		{"metadata", nil, nil, "169.254.169.254", true},
+		{"metadata IPv6", nil, nil, "fd00:ec2::254", true},
+		{"link-local IPv6 with zone", nil, nil, "fe80::1%eth0", true},
+		{"unique local IPv6", nil, nil, "fd12:3456::1", true},
+		{"IPv4-mapped loopback", nil, nil, "::ffff:127.0.0.1", true},
+		{"IPv4-mapped metadata", nil, nil, "::ffff:169.254.169.254", true},
+		{"IPv4-compatible loopback", nil, nil, "::127.0.0.1", true},
+		{"NAT64 loopback", nil, nil, "64:ff9b::7f00:1", true},
+		{"NAT64 metadata", nil, nil, "64:ff9b::a9fe:a9fe", true},
+		{"NAT64 public", nil, nil, "64:ff9b::808:808", false},
+		{"local-use NAT64", nil, nil, "64:ff9b:1::808:808", true},
+		{"6to4 loopback", nil, nil, "2002:7f00:1::", true},
+		{"6to4 private", nil, nil, "2002:c0a8:101::1", true},
+		{"6to4 public", nil, nil, "2002:808:808::1", false},
+		{"teredo private client", nil, nil, "2001:0:4136:e378:8000:63bf:3f57:fefe", true},
+		{"allowed private", []string{"10.0.0.0/8"}, nil, "10.1.2.3", false},
+		{"allowed does not cover other ranges", []string{"10.0.0.0/8"}, nil, "127.0.0.1", true},
+		{"denied public", nil, []string{"203.0.113.0/24"}, "203.0.113.7", true},
+		{"denied takes precedence", []string{"127.0.0.0/8"}, []string{"127.0.0.1/32"}, "127.0.0.1", true},
+		{"denied embedded IPv4", nil, []string{"203.0.113.0/24"}, "64:ff9b::cb00:7107", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewIPFilter(tt.allowed, tt.denied)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = filter.Check(netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%s) error = %v, want error %v", tt.addr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIPFilterControl(t *testing.T) {
+	filter, err := NewIPFilter(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"8.8.8.8:443", false},
+		{"[2606:4700::1]:443", false},
+		{"127.0.0.1:8080", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:80", true},
+		{"[64:ff9b::7f00:1]:80", true},
+		{"[2002:7f00:1::]:80", true},
+		{"[fe80::1%eth0]:80", true},
+		{"localhost:80", true},
+		{"127.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := filter.Control("tcp", tt.address, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Control(%s) error = %v, want error %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewIPFilterInvalidCIDR(t *testing.T) {
+	if _, err := NewIPFilter([]string{"10.0.0.0"}, nil); err == nil {
+		t.Error("expected error for CIDR without prefix length")
+	}
+	if _, err := NewIPFilter(nil, []string{"not a cidr"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	KeywordPrefixWord   = "word:"
+	KeywordPrefixRegex  = "re:"
+	KeywordPrefixPhrase = "phrase:"
+)
+
+// Characters which are not part of the word, used instead of \b which supports only ASCII
+const keywordBoundary = `(?:^|[^\p{L}\p{N}_])`
+const keywordBoundaryEnd = `(?:$|[^\p{L}\p{N}_])`
+
+// Keyword is the pattern matched against the message text. It is compiled when the config is decoded.
+// Supported forms:
+//   - `pm` - case insensitive substring
+//   - `word:pm` - case insensitive whole word
+//   - `phrase:"open a ticket"` - case insensitive whole words separated by any whitespace
+//   - `re:(?i)dm\s+me` - regular expression
+type Keyword struct {
+	raw     string
+	pattern *regexp.Regexp
+	// group is the index of the regexp group with the matched text, the boundaries are not part of the match
+	group int
+}
+
+type KeywordMatch struct {
+	Keyword Keyword
+	Text    string
+	// Start and End are positions of the matched text in characters
+	Start int
+	End   int
+}
+
+func ParseKeyword(raw string) (Keyword, error) {
+	keyword := Keyword{raw: raw}
+
+	var expr string
+	switch {
+	case strings.HasPrefix(raw, KeywordPrefixRegex):
+		expr = strings.TrimPrefix(raw, KeywordPrefixRegex)
+	case strings.HasPrefix(raw, KeywordPrefixWord):
+		word := strings.TrimSpace(strings.TrimPrefix(raw, KeywordPrefixWord))
+		if word == "" {
+			return keyword, fmt.Errorf("empty word in keyword %q", raw)
+		}
+
+		expr = fmt.Sprintf("(?i)%s(%s)%s", keywordBoundary, regexp.QuoteMeta(word), keywordBoundaryEnd)
+		keyword.group = 1
+	case strings.HasPrefix(raw, KeywordPrefixPhrase):
+		phrase := strings.TrimSpace(strings.TrimPrefix(raw, KeywordPrefixPhrase))
+		if unquoted, err := strconv.Unquote(phrase); err == nil {
+			phrase = unquoted
+		}
+
+		words := strings.Fields(phrase)
+		if len(words) < 1 {
+			return keyword, fmt.Errorf("empty phrase in keyword %q", raw)
+		}
+		for idx := range words {
+			words[idx] = regexp.QuoteMeta(words[idx])
+		}
+
+		expr = fmt.Sprintf(`(?i)%s(%s)%s`, keywordBoundary, strings.Join(words, `\s+`), keywordBoundaryEnd)
+		keyword.group = 1
+	default:
+		if strings.TrimSpace(raw) == "" {
+			return keyword, fmt.Errorf("empty keyword")
+		}
+
+		expr = "(?i)" + regexp.QuoteMeta(raw)
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return keyword, fmt.Errorf("invalid keyword %q: %w", raw, err)
+	}
+	// Pattern matching the empty text matches every message, e.g. `re:` or `re:a*`
+	if pattern.MatchString("") {
+		return keyword, fmt.Errorf("keyword %q matches empty text, it would match every message", raw)
+	}
+	keyword.pattern = pattern
+
+	return keyword, nil
+}
+
+func (k *Keyword) UnmarshalText(text []byte) error {
+	keyword, err := ParseKeyword(string(text))
+	if err != nil {
+		return err
+	}
+
+	*k = keyword
+	return nil
+}
+
+func (k Keyword) MarshalText() ([]byte, error) {
+	return []byte(k.raw), nil
+}
+
+func (k Keyword) String() string {
+	return k.raw
+}
+
+// Match returns the first match of the keyword in the text
+func (k Keyword) Match(text string) (KeywordMatch, bool) {
+	if k.pattern == nil {
+		return KeywordMatch{}, false
+	}
+
+	indexes := k.pattern.FindStringSubmatchIndex(text)
+	if indexes == nil {
+		return KeywordMatch{}, false
+	}
+
+	start, end := indexes[2*k.group], indexes[2*k.group+1]
+	return KeywordMatch{
+		Keyword: k,
+		Text:    text[start:end],
+		Start:   utf8.RuneCountInString(text[:start]),
+		End:     utf8.RuneCountInString(text[:end]),
+	}, true
+}
+
+func (m KeywordMatch) String() string {
+	return fmt.Sprintf("%q at characters %d-%d", m.Text, m.Start+1, m.End)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLinkResolverMaxHops     = 5
+	DefaultLinkResolverMaxBodySize = 256 * 1024
+	DefaultLinkResolverTimeout     = 10 * time.Second
+	LinkResolverRequestTimeout     = 5 * time.Second
+)
+
+// How the resolver got to the URL
+const (
+	LinkHopMessage    = "message"
+	LinkHopRedirect   = "redirect"
+	LinkHopMeta       = "meta refresh"
+	LinkHopJavaScript = "javascript"
+	LinkHopEmbedded   = "embedded in page"
+)
+
+var (
+	metaTagRegex        = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	metaRefreshRegex    = regexp.MustCompile(`(?is)http-equiv\s*=\s*["']?\s*refresh`)
+	metaRefreshURLRegex = regexp.MustCompile(`(?is)content\s*=\s*["']?[^"'>]*?url\s*=\s*['"]?([^"'>\s]+)`)
+
+	// Examples:
+	//	- window.location.href = "https://..."
+	//	- location.replace('https://...')
+	jsRedirectRegex = regexp.MustCompile(`(?i)\blocation(?:\.href)?\s*=\s*["']([^"']+)["']|\blocation\.(?:replace|assign)\(\s*["']([^"']+)["']`)
+	// Links with the invitation in the page, e.g. in <a href> or in the script
+	embeddedInviteRegex = regexp.MustCompile(`(?i)(?:https?:)?(?:\\?/){2}(?:www\.)?(?:discord(?:app)?\.com(?:\\?/)invite|discord\.gg)(?:\\?/)[\w-]+`)
+)
+
+type LinkHop struct {
+	URL string
+	// Status is the HTTP status code, it is 0 when the URL was not requested
+	Status int
+	Via    string
+}
+
+// LinkChain is the list of URLs visited from the link in the message to the invitation
+type LinkChain struct {
+	Hops        []LinkHop
+	InviteCodes []string
+	// Err is set when the chain could not be followed to the end
+	Err error
+}
+
+func (c LinkChain) String() string {
+	lines := []string{}
+	for idx, hop := range c.Hops {
+		status := ""
+		if hop.Status > 0 {
+			status = fmt.Sprintf(" [%d]", hop.Status)
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s%s (%s)", idx+1, escapeMarkdown(hop.URL), status, hop.Via))
+	}
+	if c.Err != nil {
+		lines = append(lines, escapeMarkdown(c.Err.Error()))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// LinkResolver follows the link hop by hop, every HTTP redirect, meta refresh and javascript redirect is recorded.
+// Scammers hide invitations behind custom domains and link shorteners which redirect to the invitation.
+type LinkResolver struct {
+	client      *http.Client
+	maxHops     int
+	maxBodySize int64
+}
+
+func NewLinkResolver(config ConfigLinkResolver) (*LinkResolver, error) {
+	filter, err := NewIPFilter(config.AllowedCIDRs, config.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	client := LinkCheckHttpClient(LinkResolverRequestTimeout, filter)
+	// Redirects are followed by the resolver, so every hop is recorded
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &LinkResolver{
+		client:      client,
+		maxHops:     DefaultLinkResolverMaxHops,
+		maxBodySize: config.maxBodySize(),
+	}, nil
+}
+
+func (c ConfigLinkResolver) maxBodySize() int64 {
+	if c.MaxBodySize <= 0 {
+		return DefaultLinkResolverMaxBodySize
+	}
+
+	return c.MaxBodySize
+}
+
+// Resolve follows the link until it leads to the invitation, the page without redirect or the hops limit is reached
+func (r *LinkResolver) Resolve(ctx context.Context, link string) LinkChain {
+	chain := LinkChain{}
+
+	via := LinkHopMessage
+	for hop := 0; hop <= r.maxHops; hop++ {
+		if codes := findInviteCodes(link); len(codes) > 0 {
+			chain.Hops = append(chain.Hops, LinkHop{URL: link, Via: via})
+			chain.InviteCodes = codes
+			return chain
+		}
+
+		// We cannot just throw the found link into the http.Get function because scammers often put malformed chars there
+		req, err := BuildInvitationCheckHttpRequest(ctx, link)
+		if err != nil {
+			chain.Hops = append(chain.Hops, LinkHop{URL: link, Via: via})
+			chain.Err = fmt.Errorf("invalid URL: %w", err)
+			return chain
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			chain.Hops = append(chain.Hops, LinkHop{URL: link, Via: via})
+			chain.Err = fmt.Errorf("cannot open page: %w", err)
+			return chain
+		}
+		chain.Hops = append(chain.Hops, LinkHop{URL: link, Status: resp.StatusCode, Via: via})
+
+		next, nextVia, err := r.nextHop(resp)
+		resp.Body.Close()
+		if err != nil {
+			chain.Err = err
+			return chain
+		}
+		if next == "" {
+			return chain
+		}
+
+		link, via = next, nextVia
+		// Invitations embedded in the page are not requested, they are only recorded
+		if via == LinkHopEmbedded {
+			chain.Hops = append(chain.Hops, LinkHop{URL: link, Via: via})
+			chain.InviteCodes = findInviteCodes(link)
+			return chain
+		}
+	}
+
+	chain.Err = fmt.Errorf("too many redirects, stopped after %d", r.maxHops)
+	return chain
+}
+
+// nextHop finds the URL the response leads to, it is empty when the page does not redirect anywhere
+func (r *LinkResolver) nextHop(resp *http.Response) (string, string, error) {
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return "", "", nil
+		}
+
+		next, err := resolveReference(resp.Request.URL, location)
+		return next, LinkHopRedirect, err
+	}
+
+	body, err := r.readBody(resp)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read page: %w", err)
+	}
+
+	if embedded := embeddedInviteRegex.FindString(body); embedded != "" {
+		embedded = strings.ReplaceAll(embedded, `\/`, "/")
+		next, err := resolveReference(resp.Request.URL, embedded)
+		return next, LinkHopEmbedded, err
+	}
+
+	for _, tag := range metaTagRegex.FindAllString(body, -1) {
+		if !metaRefreshRegex.MatchString(tag) {
+			continue
+		}
+
+		if match := metaRefreshURLRegex.FindStringSubmatch(tag); match != nil {
+			next, err := resolveReference(resp.Request.URL, match[1])
+			return next, LinkHopMeta, err
+		}
+	}
+
+	if match := jsRedirectRegex.FindStringSubmatch(body); match != nil {
+		location := match[1]
+		if location == "" {
+			location = match[2]
+		}
+
+		next, err := resolveReference(resp.Request.URL, location)
+		return next, LinkHopJavaScript, err
+	}
+
+	return "", "", nil
+}
+
+// readBody reads up to maxBodySize bytes of the decompressed body, the rest of the page is ignored
+func (r *LinkResolver) readBody(resp *http.Response) (string, error) {
+	var reader io.Reader = resp.Body
+
+	// Accept-Encoding is set in the request, so the transport does not decompress the body
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "deflate":
+		zlibReader, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		defer zlibReader.Close()
+		reader = zlibReader
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, r.maxBodySize))
+	// Truncated compressed stream is expected when the page is larger than the limit
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// resolveReference returns the absolute URL of the location found in the page, only http and https links are followed
+func resolveReference(base *url.URL, location string) (string, error) {
+	reference, err := url.Parse(strings.TrimSpace(html.UnescapeString(location)))
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect location %q: %w", location, err)
+	}
+
+	next := base.ResolveReference(reference)
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return "", fmt.Errorf("unsupported redirect location %q", location)
+	}
+
+	return next.String(), nil
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
 )
 
 func main() {
 	args := os.Args[1:]
 
+	if len(args) > 0 && args[0] == "validate" {
+		os.Exit(validateCommand(args[1:]))
+	}
+
 	if len(args) < 1 {
-		panic(fmt.Sprintf("invalid config path. usage: %s <config-path>", os.Args[0]))
+		panic(fmt.Sprintf("invalid config path. usage: %s <config-path> or %s validate [--online] <config-path>", os.Args[0], os.Args[0]))
 	}
 
 	config, err := ReadConfigFile(args[0])
@@ -19,6 +25,12 @@ func main() {
 		panic(fmt.Sprintf("failed to parse config file: %s", err.Error()))
 	}
 
+	if issues := config.Check(); len(issues) > 0 {
+		if PrintConfigIssues(os.Stderr, args[0], issues) {
+			os.Exit(1)
+		}
+	}
+
 	level := zap.NewAtomicLevelAt(zap.InfoLevel)
 	if config.Debug {
 		level = zap.NewAtomicLevelAt(zap.DebugLevel)
@@ -32,7 +44,47 @@ func main() {
 		panic(err)
 	}
 
-	if err := Run(logger, config); err != nil {
+	if err := Run(logger, args[0], config); err != nil {
 		panic(err)
 	}
 }
+
+// validateCommand checks the config file and returns the exit code
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	online := flags.Bool("online", false, "check that channels and roles exist in the guilds")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s validate [--online] <config-path>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	configPath := flags.Arg(0)
+
+	config, err := ReadConfigFile(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, err.Error())
+		return 1
+	}
+
+	issues := config.Check()
+	if *online && config.BotToken != "" {
+		discord, err := discordgo.New("Bot " + config.BotToken)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to initialize discord client: %s\n", err.Error())
+			return 1
+		}
+
+		issues = append(issues, config.CheckOnline(discord)...)
+	}
+
+	if PrintConfigIssues(os.Stdout, configPath, issues) {
+		return 1
+	}
+
+	return 0
+}
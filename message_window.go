@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMessageWindowDuration = 30 * time.Second
+	DefaultMessageWindowSize     = 5
+)
+
+type WindowMessage struct {
+	ID        string
+	Text      string
+	Timestamp time.Time
+}
+
+type messageWindowKey struct {
+	GuildID   string
+	ChannelID string
+	AuthorID  string
+}
+
+// MessageWindows keeps recent messages of every author in every channel, so the invitation split
+// into several messages, e.g. "discord", ".gg/", "abc123", can be checked as one text
+type MessageWindows struct {
+	mut     sync.Mutex
+	windows map[messageWindowKey][]WindowMessage
+}
+
+func NewMessageWindows() *MessageWindows {
+	return &MessageWindows{
+		windows: map[messageWindowKey][]WindowMessage{},
+	}
+}
+
+func (c ConfigDeleteInviteLinks) window() time.Duration {
+	if c.Window <= 0 {
+		return DefaultMessageWindowDuration
+	}
+
+	return c.Window
+}
+
+func (c ConfigDeleteInviteLinks) windowSize() int {
+	if c.WindowSize <= 0 {
+		return DefaultMessageWindowSize
+	}
+
+	return c.WindowSize
+}
+
+// Add puts the message in the window of its author and returns the copy of the window, the oldest message first.
+// Messages older than duration and above the size are dropped. The edited message replaces its previous text.
+func (w *MessageWindows) Add(message *discordgo.Message, text string, duration time.Duration, size int) []WindowMessage {
+	key := messageWindowKey{GuildID: message.GuildID, ChannelID: message.ChannelID, AuthorID: message.Author.ID}
+
+	timestamp := message.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	window := []WindowMessage{}
+	edited := false
+	for _, recent := range w.windows[key] {
+		if time.Since(recent.Timestamp) > duration {
+			continue
+		}
+
+		if recent.ID == message.ID {
+			recent.Text = text
+			edited = true
+		}
+		window = append(window, recent)
+	}
+	if !edited {
+		window = append(window, WindowMessage{ID: message.ID, Text: text, Timestamp: timestamp})
+	}
+
+	if len(window) > size {
+		window = window[len(window)-size:]
+	}
+	w.windows[key] = window
+
+	return append([]WindowMessage{}, window...)
+}
+
+// Clear removes the window of the author, so the deleted messages are not joined with the next ones
+func (w *MessageWindows) Clear(message *discordgo.Message) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	delete(w.windows, messageWindowKey{GuildID: message.GuildID, ChannelID: message.ChannelID, AuthorID: message.Author.ID})
+}
+
+// Prune removes windows without messages newer than duration and returns the number of removed windows
+func (w *MessageWindows) Prune(duration time.Duration) int {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	removed := 0
+	for key, window := range w.windows {
+		if len(window) > 0 && time.Since(window[len(window)-1].Timestamp) <= duration {
+			continue
+		}
+
+		delete(w.windows, key)
+		removed++
+	}
+
+	return removed
+}
+
+// joinWindowText joins texts of the messages without any separator, so the parts of the link become the whole link
+func joinWindowText(window []WindowMessage) string {
+	parts := []string{}
+	for _, message := range window {
+		parts = append(parts, message.Text)
+	}
+
+	return newWindowText(parts).text
+}
+
+// windowText is the joined text of the window messages, boundaries are offsets where the next message starts
+type windowText struct {
+	text       string
+	boundaries []int
+}
+
+func newWindowText(parts []string) windowText {
+	joined := windowText{}
+	for idx, part := range parts {
+		if idx > 0 {
+			joined.boundaries = append(joined.boundaries, len(joined.text))
+		}
+		joined.text += part
+	}
+
+	return joined
+}
+
+// spans reports whether the text between start and end is split into more than one message
+func (t windowText) spans(start, end int) bool {
+	for _, boundary := range t.boundaries {
+		if start < boundary && boundary < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *DiscordBot) PruneMessageWindows(ctx context.Context, logger *zap.Logger) {
+	t := time.NewTicker(cacheValid)
+
+	for {
+		select {
+		case <-t.C:
+			// The window may be configured per guild, windows are kept for the longest one
+			removed := b.messageWindows.Prune(b.Config().maxMessageWindow())
+			logger.Sugar().Debugf("Pruned %d message windows", removed)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters which look like latin letters to these letters. Only the characters
+// used by spammers are here, see https://www.unicode.org/Public/security/latest/confusables.txt
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'г': 'r', 'ѕ': 's', 'т': 't', 'ц': 'u', 'ѵ': 'v', 'ԝ': 'w',
+	'х': 'x', 'у': 'y', 'ӏ': 'l',
+	'А': 'A', 'В': 'B', 'С': 'C', 'Е': 'E', 'Н': 'H', 'І': 'I', 'Ј': 'J', 'К': 'K', 'М': 'M', 'О': 'O',
+	'Р': 'P', 'Ѕ': 'S', 'Т': 'T', 'Х': 'X', 'Ү': 'Y', 'Ԁ': 'D', 'Ԍ': 'G', 'Ӏ': 'I',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+	'χ': 'x', 'γ': 'y',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O',
+	'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// Latin letters not handled by NFKC
+	'ı': 'i', 'ȷ': 'j', 'ɡ': 'g', 'ɑ': 'a', 'ɩ': 'i', 'ʟ': 'L', 'ɴ': 'N', 'ᴅ': 'D', 'ᴄ': 'C', 'ᴏ': 'O',
+	'ꜱ': 'S', 'ʀ': 'R',
+}
+
+// confusablePunctuation is folded everywhere, it is needed to find the links, e.g. "discord。gg"
+var confusablePunctuation = map[rune]rune{
+	'․': '.', '‧': '.', '。': '.', '｡': '.', '∕': '/', '⁄': '/', '⧸': '/', '＼': '\\',
+}
+
+// URL punctuation inside the word marks the link, e.g. "dіscord.gg"
+const urlPunctuation = ".:/\\"
+
+// Separators put between characters of the single word, e.g. "d-i-s-c-o-r-d"
+const wordSeparators = "-_*|~+•·"
+
+// Whitespace around URL punctuation, e.g. "discord . gg / invite". The space after the punctuation is removed only
+// when the word before it looks like the part of the link, e.g. "discord", "https" or the domain "bit.ly",
+// otherwise every sentence would be joined with the next one, e.g. "Hello. World".
+var (
+	spacesBeforeURLPunctuation = regexp.MustCompile(`([\p{L}\p{N}.:/\\])\s+([.:/\\])`)
+	spacesAfterURLPunctuation  = regexp.MustCompile(`(?i)((?:\b(?:https?|discord(?:app)?|gg|invite)|\.\p{L}{2,})[.:/\\]+)\s+([\p{L}\p{N}])`)
+)
+
+// normalizeText prepares the text for matching, so obfuscated text like "d i s c o r d . g g", "dіscord.gg"
+// with the Cyrillic і or text with zero-width characters is matched as "discord.gg". The result is used only
+// for matching, it is not meant to be displayed instead of the original text.
+func normalizeText(text string) string {
+	// NFKC turns fullwidth, circled, mathematical, etc. letters into the plain ones, NFD splits
+	// the accents from letters, so they can be removed together with the other combining characters
+	text = norm.NFD.String(norm.NFKC.String(text))
+
+	text = strings.Map(func(r rune) rune {
+		// Zero-width spaces, joiners, BOM, soft hyphen and the other invisible characters
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+			return -1
+		}
+		if folded, found := confusablePunctuation[r]; found {
+			return folded
+		}
+
+		return r
+	}, text)
+
+	text = norm.NFC.String(text)
+	text = collapseSpacedLetters(text)
+	text = foldConfusables(text)
+
+	// Matches overlap, e.g. "https : //", so spaces are removed until nothing changes. Every pass makes the text shorter.
+	for {
+		joined := spacesBeforeURLPunctuation.ReplaceAllString(text, "$1$2")
+		joined = spacesAfterURLPunctuation.ReplaceAllString(joined, "$1$2")
+		if joined == text {
+			break
+		}
+		text = joined
+	}
+
+	return text
+}
+
+// collapseSpacedLetters joins at least three single characters separated by whitespace or word separators,
+// e.g. "d i s c o r d" or "d-i-s-c-o-r-d" become "discord". The dots and slashes are kept, so the links stay valid.
+func collapseSpacedLetters(text string) string {
+	isSeparator := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(wordSeparators, r)
+	}
+	isLetter := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}
+
+	// Single character is not the part of the longer word, the URL punctuation is joined too, e.g. "d i s c o r d . g g"
+	runes := []rune(text)
+	single := func(p int) bool {
+		return (isLetter(runes[p]) || strings.ContainsRune(".:/\\", runes[p])) &&
+			(p == 0 || !isLetter(runes[p-1])) &&
+			(p+1 == len(runes) || !isLetter(runes[p+1]))
+	}
+
+	result := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); {
+		if !single(i) {
+			result = append(result, runes[i])
+			i++
+			continue
+		}
+
+		run := []rune{runes[i]}
+		last := i
+		for {
+			next := last + 1
+			for next < len(runes) && isSeparator(runes[next]) {
+				next++
+			}
+			if next == last+1 || next >= len(runes) || !single(next) {
+				break
+			}
+
+			run = append(run, runes[next])
+			last = next
+		}
+
+		if len(run) < 3 {
+			result = append(result, runes[i])
+			i++
+			continue
+		}
+
+		result = append(result, run...)
+		i = last + 1
+	}
+
+	return string(result)
+}
+
+// foldConfusables replaces confusable characters only in words mixing them with latin letters or in links,
+// e.g. "dіscord.gg" with the Cyrillic і. Words written only in Cyrillic or Greek are not changed, otherwise
+// ordinary messages would match latin keywords, e.g. "рм" would become "pm".
+func foldConfusables(text string) string {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r) || strings.ContainsRune(urlPunctuation+"-_", r)
+	}
+
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		word := runes[start:end]
+		if isMixedWord(word) {
+			for idx, r := range word {
+				if folded, found := confusables[r]; found {
+					word[idx] = folded
+				}
+			}
+		}
+		start = end
+	}
+
+	return string(runes)
+}
+
+// isMixedWord reports whether the word contains latin letters or the URL punctuation inside it,
+// the punctuation at the end of the word is the end of the sentence, e.g. "дела."
+func isMixedWord(word []rune) bool {
+	for idx, r := range word {
+		if unicode.Is(unicode.Latin, r) {
+			return true
+		}
+		if idx > 0 && idx < len(word)-1 && strings.ContainsRune(urlPunctuation, r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "DM me for free nitro", "DM me for free nitro"},
+		{"spaced letters", "d i s c o r d . g g / abc", "discord.gg/abc"},
+		{"dashed letters", "d-i-s-c-o-r-d.gg/x", "discord.gg/x"},
+		{"cyrillic i in link", "dіscord.gg/abc", "discord.gg/abc"},
+		{"cyrillic letters in latin word", "frее nitrо", "free nitro"},
+		{"zero width space", "disc​ord.gg/x", "discord.gg/x"},
+		{"zero width joiner", "disc‍ord.gg/x", "discord.gg/x"},
+		{"fullwidth", "ｄｉｓｃｏｒｄ．ｇｇ／ａｂｃ", "discord.gg/abc"},
+		{"ideographic full stop", "discord。gg/abc", "discord.gg/abc"},
+		{"combining characters", "café naïve", "cafe naive"},
+		{"spaces around punctuation", "join discord . gg / abc now", "join discord.gg/abc now"},
+		{"spaced scheme", "https : // discord.com / invite / abc", "https://discord.com/invite/abc"},
+		{"short words are not joined", "I am a cat", "I am a cat"},
+		{"cyrillic text is not folded", "Привет, как дела? рм", "Привет, как дела? рм"},
+		{"cyrillic sentence end is not folded", "как дела. рм.", "как дела. рм."},
+		{"sentences are not joined", "Hello. World, note: free stuff", "Hello. World, note: free stuff"},
+		{"space after domain", "discord. gg/ abc", "discord.gg/abc"},
+		{"space after link domain", "see bit.ly/ abc", "see bit.ly/abc"},
+		{"space after invite path", "discord.com/invite/ abc", "discord.com/invite/abc"},
+		{"greek text is not folded", "καλημέρα", "καλημερα"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.text); got != tt.want {
+				t.Errorf("normalizeText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedKeywordMatch(t *testing.T) {
+	keyword, err := ParseKeyword("word:pm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Привет, как дела? рм", false},
+		{"send me a рm", true},
+		{"p m me", false},
+	}
+
+	for _, tt := range tests {
+		if _, got := keyword.Match(normalizeText(tt.text)); got != tt.want {
+			t.Errorf("match %q in normalized %q = %v, want %v", keyword, normalizeText(tt.text), got, tt.want)
+		}
+	}
+}
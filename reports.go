@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// Discord limits, see: https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	embedTitleLimit      = 256
+	embedFieldNameLimit  = 256
+	embedFieldValueLimit = 1024
+	embedFieldsLimit     = 25
+
+	// Description limit is 4096, but the whole embed cannot exceed 6000 characters
+	// so We leave space for the fields
+	reportContentChunkLimit = 3000
+
+	// reportUploadLimit is the max size of files in the single message on servers without boosts
+	reportUploadLimit = 10 * 1024 * 1024
+)
+
+const (
+	ReportColorSuspicious = 0xE67E22
+	ReportColorDeleted    = 0xE74C3C
+	ReportColorEdited     = 0xF1C40F
+	ReportColorCommand    = 0x3498DB
+)
+
+// Report is posted as an embed to the report channel
+type Report struct {
+	Title   string
+	Color   int
+	Feature string
+	// Rule describes what triggered the report, e.g. matched keyword
+	Rule string
+
+	Author *discordgo.User
+	// Message the report is about. It is used for the jump link and timestamps
+	Message   *discordgo.Message
+	GuildID   string
+	ChannelID string
+
+	// Content is displayed in the code block and split when it is too long
+	Content string
+	Fields  []*discordgo.MessageEmbedField
+	// Files are uploaded in the separate message after the report
+	Files []*discordgo.File
+}
+
+func (r Report) Embeds() []*discordgo.MessageEmbed {
+	guildID, channelID := r.GuildID, r.ChannelID
+	if r.Message != nil {
+		guildID, channelID = r.Message.GuildID, r.Message.ChannelID
+	}
+
+	fields := []*discordgo.MessageEmbedField{}
+	addField := func(name, value string, inline bool) {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   truncateText(name, embedFieldNameLimit),
+			Value:  truncateText(value, embedFieldValueLimit),
+			Inline: inline,
+		})
+	}
+
+	if r.Author != nil {
+		addField("Author", fmt.Sprintf("<@%s> (%s)", r.Author.ID, escapeMarkdown(r.Author.Username)), true)
+	}
+	if channelID != "" {
+		addField("Channel", fmt.Sprintf("<#%s>", channelID), true)
+	}
+	if r.Message != nil {
+		if guildID != "" {
+			addField("Message", fmt.Sprintf("[Jump to message](%s)", messageJumpLink(guildID, channelID, r.Message.ID)), true)
+		}
+		if !r.Message.Timestamp.IsZero() {
+			addField("Posted", discordTimestamp(r.Message.Timestamp), true)
+		}
+		if r.Message.EditedTimestamp != nil {
+			addField("Edited", discordTimestamp(*r.Message.EditedTimestamp), true)
+		}
+	}
+	if r.Feature != "" {
+		addField("Feature", r.Feature, true)
+	}
+	if r.Rule != "" {
+		addField("Matched rule", fmt.Sprintf("`%s`", strings.ReplaceAll(r.Rule, "`", "'")), true)
+	}
+	for _, field := range r.Fields {
+		addField(field.Name, field.Value, field.Inline)
+	}
+
+	if len(fields) > embedFieldsLimit {
+		fields = fields[:embedFieldsLimit]
+	}
+
+	// Escaped content is longer than the raw one, so it is split after escaping
+	chunks := splitText(escapeCodeBlock(r.Content), reportContentChunkLimit)
+	embeds := []*discordgo.MessageEmbed{{
+		Title:     truncateText(r.Title, embedTitleLimit),
+		Color:     r.Color,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Fields:    fields,
+	}}
+	if len(chunks) == 0 {
+		return embeds
+	}
+
+	embeds[0].Description = wrapCodeBlock(chunks[0])
+	for i, chunk := range chunks[1:] {
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:       truncateText(fmt.Sprintf("%s (continued %d/%d)", r.Title, i+2, len(chunks)), embedTitleLimit),
+			Color:       r.Color,
+			Description: wrapCodeBlock(chunk),
+		})
+	}
+
+	return embeds
+}
+
+// sendReport posts the report to the report channel. Every embed is sent as a separate
+// message, because single message cannot exceed 6000 characters in all embeds.
+func sendReport(logger *zap.Logger, discord *discordgo.Session, reportChannel string, report Report) {
+	channelID := report.ChannelID
+	logFields := []zap.Field{zap.String("feature", report.Feature)}
+	if report.Message != nil {
+		channelID = report.Message.ChannelID
+		logFields = append(logFields, zap.String("message", report.Message.ID))
+	}
+	logFields = append(logFields, zap.String("channel", channelID))
+	if report.Author != nil {
+		logFields = append(logFields, zap.String("author", report.Author.ID))
+	}
+	if report.Rule != "" {
+		logFields = append(logFields, zap.String("rule", report.Rule))
+	}
+	logger.Info(report.Title, logFields...)
+
+	for _, embed := range report.Embeds() {
+		if _, err := discord.ChannelMessageSendEmbeds(reportChannel, []*discordgo.MessageEmbed{embed}); err != nil {
+			logger.Error("failed to send report", zap.String("title", embed.Title), zap.Error(err))
+			return
+		}
+	}
+
+	// Every file is uploaded in the separate message, all the files of the single message cannot exceed reportUploadLimit
+	for idx, file := range report.Files {
+		content := fmt.Sprintf("Attachments for: %s", report.Title)
+		if len(report.Files) > 1 {
+			content = fmt.Sprintf("Attachments for: %s (%d/%d)", report.Title, idx+1, len(report.Files))
+		}
+
+		if _, err := discord.ChannelMessageSendComplex(reportChannel, &discordgo.MessageSend{
+			Content: content,
+			Files:   []*discordgo.File{file},
+		}); err != nil {
+			logger.Error("failed to upload report file", zap.String("file", file.Name), zap.Error(err))
+		}
+	}
+}
+
+// nonEmpty returns placeholder for the empty text, discord rejects embed fields without value
+func nonEmpty(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return "*empty*"
+	}
+
+	return text
+}
+
+func messageJumpLink(guildID, channelID, messageID string) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, channelID, messageID)
+}
+
+func discordTimestamp(t time.Time) string {
+	return fmt.Sprintf("<t:%d:f> (<t:%d:R>)", t.Unix(), t.Unix())
+}
+
+// codeBlockFenceLength is the number of characters added by wrapCodeBlock, two fences with new lines and the zero width space
+const codeBlockFenceLength = 9
+
+// codeBlock escapes the text and wraps it in the code block, the whole block does not exceed the limit of characters
+func codeBlock(text string, limit int) string {
+	return wrapCodeBlock(truncateText(escapeCodeBlock(text), limit-codeBlockFenceLength))
+}
+
+// escapeCodeBlock puts the zero width space between backticks, so backticks in the text cannot close the code block.
+// The escaped text is longer, so it must be escaped before it is split or truncated.
+func escapeCodeBlock(text string) string {
+	var escaped strings.Builder
+	previous := rune(0)
+	for _, r := range text {
+		if r == '`' && previous == '`' {
+			escaped.WriteRune('\u200b')
+		}
+		escaped.WriteRune(r)
+		previous = r
+	}
+
+	return escaped.String()
+}
+
+// wrapCodeBlock wraps the text already escaped with escapeCodeBlock in the code block
+func wrapCodeBlock(text string) string {
+	if strings.HasSuffix(text, "`") {
+		text += "\u200b"
+	}
+
+	return "```\n" + text + "\n```"
+}
+
+var markdownRegex = regexp.MustCompile("([\\\\*_~`|>\\[\\]])")
+
+func escapeMarkdown(text string) string {
+	return markdownRegex.ReplaceAllString(text, `\$1`)
+}
+
+// truncateText cuts text to the given number of characters without breaking utf-8 runes
+func truncateText(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
+
+// splitText splits text into chunks of the given number of characters. It prefers
+// to split on new lines.
+func splitText(text string, limit int) []string {
+	chunks := []string{}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if len(runes) <= limit {
+			chunks = append(chunks, string(runes))
+			break
+		}
+
+		end := limit
+		for i := limit - 1; i > limit/2; i-- {
+			if runes[i] == '\n' {
+				end = i + 1
+				break
+			}
+		}
+
+		chunks = append(chunks, string(runes[:end]))
+		runes = runes[end:]
+	}
+
+	return chunks
+}
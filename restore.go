@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRestoreCommandName = "restore"
+
+	RestoreOptionTranscript = "transcript"
+	RestoreOptionSince      = "since"
+	RestoreOptionUntil      = "until"
+	RestoreOptionChannel    = "channel"
+
+	RestoreWebhookName = "Restored messages"
+
+	// RestoreTranscriptMaxSize limits the size of the downloaded transcript
+	RestoreTranscriptMaxSize = 32 * 1024 * 1024
+	RestoreTimeout           = 30 * time.Minute
+)
+
+func restoreCommandName(config ConfigCommandRestore) string {
+	if config.Command == "" {
+		return DefaultRestoreCommandName
+	}
+
+	return config.Command
+}
+
+func restoreApplicationCommand() ApplicationCommand {
+	return ApplicationCommand{
+		Definition: func(config ConfigGuild) *discordgo.ApplicationCommand {
+			if !config.Commands.Restore.Enabled {
+				return nil
+			}
+
+			return &discordgo.ApplicationCommand{
+				Name:        restoreCommandName(config.Commands.Restore),
+				Description: "Repost deleted messages from the wipe transcript or the message store",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Name:        RestoreOptionTranscript,
+						Description: "JSON transcript of the wipe",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        RestoreOptionSince,
+						Description: "Restore messages from the store posted within the given time, e.g. 30m, 2h, 7d",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        RestoreOptionUntil,
+						Description: "Skip messages from the store posted within the given time, e.g. 10m",
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        RestoreOptionChannel,
+						Description: "ID of the channel the messages were posted in, the current channel by default",
+					},
+				},
+			}
+		},
+		WhitelistedRoles: func(config ConfigGuild) []string {
+			return config.Commands.Restore.WhitelistedRoles
+		},
+		ActiveChannels: func(config ConfigGuild) []string {
+			return config.Commands.Restore.ActiveChannels
+		},
+		Handler: func(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, config ConfigGuild) {
+			commandRestore(logger, interaction, discord, bot, config.ReportChannel)
+		},
+	}
+}
+
+func commandRestore(
+	logger *zap.Logger,
+	interaction *discordgo.InteractionCreate,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	reportChannel string,
+) {
+	data := interaction.ApplicationCommandData()
+
+	transcriptURL := ""
+	sourceChannel := interaction.ChannelID
+	var since, until time.Duration
+	for _, option := range data.Options {
+		var err error
+
+		switch option.Name {
+		case RestoreOptionTranscript:
+			attachmentID, _ := option.Value.(string)
+			if data.Resolved != nil && data.Resolved.Attachments[attachmentID] != nil {
+				transcriptURL = data.Resolved.Attachments[attachmentID].URL
+			}
+		case RestoreOptionSince:
+			since, err = parseWipeDuration(option.StringValue())
+		case RestoreOptionUntil:
+			until, err = parseWipeDuration(option.StringValue())
+		case RestoreOptionChannel:
+			sourceChannel = strings.TrimSpace(option.StringValue())
+		}
+
+		if err != nil {
+			respondEphemeral(logger, discord, interaction, fmt.Sprintf("invalid %s value: %s", option.Name, err.Error()))
+			return
+		}
+	}
+
+	if (transcriptURL == "") == (since == 0) {
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("Use either the %s or the %s option.", RestoreOptionTranscript, RestoreOptionSince))
+		return
+	}
+	if until >= since && since > 0 {
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("%s must be shorter than %s.", RestoreOptionUntil, RestoreOptionSince))
+		return
+	}
+
+	// The message store keeps messages of all the guilds, only moderated channels of the current guild can be restored
+	if transcriptURL == "" {
+		channel, err := discord.Channel(sourceChannel)
+		if err != nil || channel.GuildID != interaction.GuildID {
+			respondEphemeral(logger, discord, interaction, fmt.Sprintf("Channel %s is not in this server.", sourceChannel))
+			return
+		}
+		if !bot.IsModeratedChannel(interaction.GuildID, sourceChannel) {
+			respondEphemeral(logger, discord, interaction, fmt.Sprintf("Channel <#%s> is not moderated, its messages are not stored.", sourceChannel))
+			return
+		}
+	}
+
+	deferEphemeral(logger, discord, interaction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), RestoreTimeout)
+	defer cancel()
+
+	var messages []TranscriptMessage
+	var err error
+	source := ""
+	if transcriptURL != "" {
+		source = "transcript"
+		messages, err = downloadTranscriptMessages(ctx, transcriptURL)
+	} else {
+		now := time.Now()
+		source = fmt.Sprintf("message store, <#%s> from %s to %s", sourceChannel, discordTimestamp(now.Add(-since)), discordTimestamp(now.Add(-until)))
+		messages, err = storedTranscriptMessages(bot, sourceChannel, now.Add(-since), now.Add(-until))
+	}
+	if err != nil {
+		logger.Error("Failed to load messages to restore", zap.Error(err))
+		editResponse(logger, discord, interaction, fmt.Sprintf("Failed to load messages: %s", err.Error()))
+		return
+	}
+
+	if len(messages) < 1 {
+		editResponse(logger, discord, interaction, "No messages to restore.")
+		return
+	}
+
+	editResponse(logger, discord, interaction, fmt.Sprintf("Restoring %d messages...", len(messages)))
+
+	restored, err := restoreMessages(ctx, logger, discord, bot, interaction.ChannelID, messages)
+	if err != nil {
+		logger.Error("Restore failed", zap.Error(err))
+		editResponse(logger, discord, interaction, fmt.Sprintf("Restore failed: %s. Messages restored: %d", err.Error(), restored))
+	} else {
+		editResponse(logger, discord, interaction, fmt.Sprintf("Messages restored: %d", restored))
+	}
+
+	sendReport(logger, discord, reportChannel, Report{
+		Title:     "Restore command received",
+		Color:     ReportColorCommand,
+		Feature:   "restore",
+		Author:    interactionUser(interaction),
+		GuildID:   interaction.GuildID,
+		ChannelID: interaction.ChannelID,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Messages restored", Value: fmt.Sprintf("%d/%d", restored, len(messages)), Inline: true},
+			{Name: "Source", Value: source},
+		},
+	})
+}
+
+func downloadTranscriptMessages(ctx context.Context, url string) ([]TranscriptMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := DefaultHttpClient(DefaultRequestTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download transcript: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download transcript: unexpected status %s", resp.Status)
+	}
+
+	transcript := Transcript{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, RestoreTranscriptMaxSize)).Decode(&transcript); err != nil {
+		return nil, fmt.Errorf("failed to decode transcript, only JSON transcripts are supported: %w", err)
+	}
+
+	return transcript.Messages, nil
+}
+
+func storedTranscriptMessages(bot *DiscordBot, channelID string, from, to time.Time) ([]TranscriptMessage, error) {
+	stored, err := bot.messages.Range(channelID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message store: %w", err)
+	}
+
+	messages := []TranscriptMessage{}
+	for _, m := range stored {
+		messages = append(messages, newTranscriptMessage(m))
+	}
+
+	return messages, nil
+}
+
+// restoreMessages reposts messages through the channel webhook with the name and the avatar of
+// the original author, the oldest first. It returns the number of restored messages.
+func restoreMessages(
+	ctx context.Context,
+	logger *zap.Logger,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	channelID string,
+	messages []TranscriptMessage,
+) (int, error) {
+	webhook, err := restoreWebhook(discord, bot, channelID)
+	if err != nil {
+		return 0, err
+	}
+
+	slices.SortFunc(messages, func(a, b TranscriptMessage) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	errors := 5
+	restored := 0
+	for _, message := range messages {
+		if err := ctx.Err(); err != nil {
+			return restored, err
+		}
+
+		if errors < 1 {
+			return restored, fmt.Errorf("too many errors when restoring messages")
+		}
+
+		marker := fmt.Sprintf("\n-# restored, originally posted %s", discordTimestamp(message.Timestamp))
+		content := message.Content
+		if len(message.Attachments) > 0 {
+			content = strings.TrimSpace(content + "\n" + strings.Join(message.Attachments, "\n"))
+		}
+
+		if _, err := discord.WebhookExecute(webhook.ID, webhook.Token, true, &discordgo.WebhookParams{
+			Content:   truncateText(content, 2000-len(marker)) + marker,
+			Username:  restoreUsername(message.AuthorName),
+			AvatarURL: message.AuthorAvatar,
+			// Restored messages must not ping anyone again
+			AllowedMentions: &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}},
+		}, discordgo.WithRetryOnRatelimit(true)); err != nil {
+			errors--
+			logger.Error("Failed to restore message", zap.String("message", message.ID), zap.Error(err))
+			continue
+		}
+
+		restored++
+	}
+
+	return restored, nil
+}
+
+// restoreWebhook returns the restore webhook of the channel, it is created when missing
+func restoreWebhook(discord *discordgo.Session, bot *DiscordBot, channelID string) (*discordgo.Webhook, error) {
+	webhooks, err := discord.ChannelWebhooks(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel webhooks: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Name == RestoreWebhookName && webhook.Token != "" && webhook.ApplicationID == bot.ApplicationID() {
+			bot.restoreWebhooks.Add(webhook.ID, true)
+			return webhook, nil
+		}
+	}
+
+	webhook, err := discord.WebhookCreate(channelID, RestoreWebhookName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	bot.restoreWebhooks.Add(webhook.ID, true)
+
+	return webhook, nil
+}
+
+// restoreUsername returns the valid webhook username, discord rejects names
+// containing "discord" or "clyde" and longer than 80 characters
+func restoreUsername(name string) string {
+	lower := strings.ToLower(name)
+	if strings.TrimSpace(name) == "" || strings.Contains(lower, "discord") || strings.Contains(lower, "clyde") {
+		return "Unknown user"
+	}
+
+	return truncateText(name, 80)
+}
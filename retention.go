@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const RetentionCheckInterval = time.Minute
+
+// ScheduleRetention starts retention wipe jobs when the schedule of the guild is due. The schedule
+// is read from the current config on every check, so reloaded config is used without the restart.
+func (b *DiscordBot) ScheduleRetention(ctx context.Context, logger *zap.Logger, discord *discordgo.Session) {
+	t := time.NewTicker(RetentionCheckInterval)
+	defer t.Stop()
+
+	lastCheck := time.Now()
+	for {
+		select {
+		case now := <-t.C:
+			for _, guildID := range b.GuildsIDs() {
+				retention := b.Config().ForGuild(guildID).Retention
+				if !retention.Enabled {
+					continue
+				}
+
+				schedule, err := cron.ParseStandard(retention.Schedule)
+				if err != nil {
+					logger.Error("invalid retention schedule", zap.String("guild", guildID), zap.Error(err))
+					continue
+				}
+
+				if schedule.Next(lastCheck).After(now) {
+					continue
+				}
+
+				runRetention(logger, discord, b, guildID, retention)
+			}
+
+			lastCheck = now
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runRetention starts the wipe job for every channel of the guild with the retention policy.
+// The default config is shared by all the guilds, so channels of other guilds are skipped.
+func runRetention(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, guildID string, retention ConfigRetention) {
+	for _, policy := range retention.Channels {
+		channel, err := discord.Channel(policy.Channel)
+		if err != nil {
+			logger.Error("failed to get retention channel", zap.String("channel", policy.Channel), zap.Error(err))
+			continue
+		}
+		if channel.GuildID != guildID {
+			continue
+		}
+
+		job := &WipeJob{
+			GuildID:   guildID,
+			ChannelID: policy.Channel,
+			Filter: WipeFilter{
+				OlderThan: policy.MaxAge,
+				Keep:      policy.MaxCount,
+			},
+			Scheduled: true,
+		}
+
+		if err := startWipeJob(logger, discord, bot, job); err != nil {
+			logger.Warn("Retention wipe skipped", zap.String("channel", policy.Channel), zap.Error(err))
+
+			sendReport(logger, discord, bot.Config().ForGuild(guildID).ReportChannel, Report{
+				Title:     "Retention wipe skipped",
+				Color:     ReportColorCommand,
+				Feature:   "retention",
+				GuildID:   guildID,
+				ChannelID: policy.Channel,
+				Fields: []*discordgo.MessageEmbedField{
+					{Name: "Reason", Value: err.Error()},
+					{Name: "Filters", Value: job.Filter.String()},
+				},
+			})
+			continue
+		}
+
+		logger.Sugar().Infof("Retention wipe job %s started in channel %s", job.ID, policy.Channel)
+	}
+}
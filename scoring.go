@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultScoreReportThreshold = 1
+	DefaultScoreKeywordWeight   = 1
+
+	// Members who joined earlier posted before the store started to record authors,
+	// so their first recorded message is not their first message in the server
+	FirstMessageJoinedWithin = 7 * 24 * time.Hour
+)
+
+type ScoreSignal struct {
+	Name   string
+	Weight float64
+	Detail string
+}
+
+// Score is the sum of weights of signals found in the message
+type Score struct {
+	Total   float64
+	Signals []ScoreSignal
+	// Keywords are keywords and rules matched in the message
+	Keywords []string
+}
+
+func (s *Score) Add(name string, weight float64, detail string) {
+	if weight == 0 {
+		return
+	}
+
+	s.Total += weight
+	s.Signals = append(s.Signals, ScoreSignal{Name: name, Weight: weight, Detail: detail})
+}
+
+// Breakdown returns every signal in the separate line
+func (s Score) Breakdown() string {
+	lines := []string{}
+	for _, signal := range s.Signals {
+		line := fmt.Sprintf("%+.2f %s", signal.Weight, signal.Name)
+		if signal.Detail != "" {
+			line += ": " + signal.Detail
+		}
+		lines = append(lines, escapeMarkdown(line))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c ConfigScore) reportThreshold() float64 {
+	if c.ReportThreshold <= 0 {
+		return DefaultScoreReportThreshold
+	}
+
+	return c.ReportThreshold
+}
+
+func (c ConfigScore) keywordWeight() float64 {
+	if c.KeywordWeight == 0 {
+		return DefaultScoreKeywordWeight
+	}
+
+	return c.KeywordWeight
+}
+
+// scoreMessage adds weights of keywords, rules and the other signals found in the message. Keywords are
+// matched against the raw text first and then against the normalized one, see normalizeText.
+func scoreMessage(logger *zap.Logger, message *discordgo.Message, text, normalized string, bot *DiscordBot, config ConfigSuspiciousMessage) Score {
+	score := Score{}
+
+	for _, keyword := range config.Keywords {
+		if detail, found := matchKeyword(keyword, text, normalized); found {
+			score.Add(fmt.Sprintf("keyword %s", keyword), config.Score.keywordWeight(), detail)
+			score.Keywords = append(score.Keywords, keyword.String())
+		}
+	}
+	for _, rule := range config.Rules {
+		if detail, found := matchKeyword(rule.Keyword, text, normalized); found {
+			score.Add(fmt.Sprintf("rule %s", rule.Keyword), rule.Weight, detail)
+			score.Keywords = append(score.Keywords, rule.Keyword.String())
+		}
+	}
+
+	if config.Score.NewAccountAge > 0 && message.Author != nil {
+		if createdAt, err := discordgo.SnowflakeTimestamp(message.Author.ID); err == nil && time.Since(createdAt) < config.Score.NewAccountAge {
+			score.Add("new account", config.Score.NewAccountWeight, fmt.Sprintf("created %s ago", time.Since(createdAt).Round(time.Minute)))
+		}
+	}
+
+	if linkRegex.MatchString(text) || linkRegex.MatchString(normalized) || isDiscordInvitation(text) {
+		score.Add("link", config.Score.LinkWeight, "")
+	}
+
+	mentions := len(message.Mentions) + len(message.MentionRoles)
+	if message.MentionEveryone {
+		mentions++
+	}
+	if mentions > 0 {
+		score.Add("mentions", config.Score.MentionWeight*float64(mentions), fmt.Sprintf("%d mentioned", mentions))
+	}
+
+	if config.Score.FirstMessageWeight != 0 && isFirstMessage(logger, message, bot) {
+		score.Add("first message in the server", config.Score.FirstMessageWeight, "")
+	}
+
+	return score
+}
+
+// matchKeyword returns the description of the keyword match in the raw or the normalized text
+func matchKeyword(keyword Keyword, text, normalized string) (string, bool) {
+	if match, found := keyword.Match(text); found {
+		return match.String(), true
+	}
+
+	if match, found := keyword.Match(normalized); found {
+		return match.String() + " in the normalized text", true
+	}
+
+	return "", false
+}
+
+// isFirstMessage reports whether it is the first message of the recently joined member
+func isFirstMessage(logger *zap.Logger, message *discordgo.Message, bot *DiscordBot) bool {
+	if message.Author == nil || message.GuildID == "" {
+		return false
+	}
+
+	if message.Member != nil && !message.Member.JoinedAt.IsZero() && time.Since(message.Member.JoinedAt) > FirstMessageJoinedWithin {
+		return false
+	}
+
+	firstMessageID, err := bot.messages.RecordAuthor(message.GuildID, message.Author.ID, message.ID)
+	if err != nil {
+		logger.Warn("failed to record message author", zap.Error(err))
+		return false
+	}
+
+	return firstMessageID == message.ID
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	MessageStoreMemory = "memory"
+	MessageStoreBolt   = "bolt"
+
+	DefaultMessageStoreRetention = 7 * 24 * time.Hour
+	DefaultMessageStorePath      = "messages.db"
+)
+
+var ErrMessageNotFound = errors.New("message not found in the store")
+
+// MessageStore keeps messages posted in the moderated channels, so We still
+// know what the message contained after it is gone from the discord state.
+type MessageStore interface {
+	Save(message *discordgo.Message) error
+	Get(channelID, messageID string) (*discordgo.Message, error)
+	// RecordAuthor saves the first message of the author in the guild and returns its ID,
+	// so the message is the first one of the author when the returned ID is the same
+	RecordAuthor(guildID, authorID, messageID string) (string, error)
+	// Range returns messages of the channel posted between from and to, the oldest first
+	Range(channelID string, from, to time.Time) ([]*discordgo.Message, error)
+	// Prune removes all the messages posted before the given time
+	Prune(olderThan time.Time) (int, error)
+	Close() error
+}
+
+func NewMessageStore(config ConfigMessageStore) (MessageStore, error) {
+	switch config.Type {
+	case "", MessageStoreMemory:
+		return NewMemoryMessageStore(), nil
+	case MessageStoreBolt:
+		path := config.Path
+		if path == "" {
+			path = DefaultMessageStorePath
+		}
+
+		return NewBoltMessageStore(path)
+	default:
+		return nil, fmt.Errorf("unknown message store type: %s", config.Type)
+	}
+}
+
+// Discord IDs are snowflakes, they start with the creation timestamp, so
+// zero padded IDs are sorted by the creation time.
+func messageStoreKey(messageID string) ([]byte, error) {
+	id, err := strconv.ParseUint(messageID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id %s: %w", messageID, err)
+	}
+
+	return []byte(fmt.Sprintf("%020d", id)), nil
+}
+
+func snowflakeFromTime(t time.Time) uint64 {
+	// Discord epoch: 2015-01-01T00:00:00Z
+	const discordEpoch = 1420070400000
+
+	ms := t.UnixMilli() - discordEpoch
+	if ms < 0 {
+		return 0
+	}
+
+	return uint64(ms) << 22
+}
+
+// mergeMessageUpdate applies the message update event on the previous version
+// of the message. Update events do not always contain the full message, e.g.
+// when discord adds embeds for links, only embeds are sent.
+func mergeMessageUpdate(before, update *discordgo.Message) *discordgo.Message {
+	if before == nil {
+		return update
+	}
+
+	merged := *before
+	if update.Content != "" {
+		merged.Content = update.Content
+	}
+	if update.EditedTimestamp != nil {
+		merged.EditedTimestamp = update.EditedTimestamp
+	}
+	if update.Embeds != nil {
+		merged.Embeds = update.Embeds
+	}
+	if update.Attachments != nil {
+		merged.Attachments = update.Attachments
+	}
+	if update.Mentions != nil {
+		merged.Mentions = update.Mentions
+	}
+
+	return &merged
+}
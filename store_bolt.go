@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltMessagesBucket = []byte("messages")
+	// boltAuthorsBucket maps guild and author ID to the ID of the first message
+	boltAuthorsBucket = []byte("authors")
+)
+
+// BoltMessageStore keeps messages in the single file on the disk, so they
+// survive the bot restarts.
+type BoltMessageStore struct {
+	db *bolt.DB
+}
+
+func NewBoltMessageStore(path string) (*BoltMessageStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message store %s: %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltMessagesBucket, boltAuthorsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	return &BoltMessageStore{db: db}, nil
+}
+
+func (s *BoltMessageStore) Save(message *discordgo.Message) error {
+	key, err := messageStoreKey(message.ID)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message %s: %w", message.ID, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMessagesBucket).Put(key, value)
+	})
+}
+
+func (s *BoltMessageStore) Get(channelID, messageID string) (*discordgo.Message, error) {
+	key, err := messageStoreKey(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var message *discordgo.Message
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltMessagesBucket).Get(key)
+		if value == nil {
+			return ErrMessageNotFound
+		}
+
+		message = &discordgo.Message{}
+		return json.Unmarshal(value, message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if message.ChannelID != channelID {
+		return nil, ErrMessageNotFound
+	}
+
+	return message, nil
+}
+
+func (s *BoltMessageStore) RecordAuthor(guildID, authorID, messageID string) (string, error) {
+	key := []byte(guildID + "/" + authorID)
+
+	firstMessageID := messageID
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltAuthorsBucket)
+		if value := bucket.Get(key); value != nil {
+			firstMessageID = string(value)
+			return nil
+		}
+
+		return bucket.Put(key, []byte(messageID))
+	})
+
+	return firstMessageID, err
+}
+
+func (s *BoltMessageStore) Range(channelID string, from, to time.Time) ([]*discordgo.Message, error) {
+	// Keys are sorted by the creation time, messages created in the last millisecond have higher keys
+	first := []byte(fmt.Sprintf("%020d", snowflakeFromTime(from)))
+	last := []byte(fmt.Sprintf("%020d", snowflakeFromTime(to.Add(time.Millisecond))))
+
+	messages := []*discordgo.Message{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltMessagesBucket).Cursor()
+
+		for key, value := cursor.Seek(first); key != nil && bytes.Compare(key, last) < 0; key, value = cursor.Next() {
+			message := &discordgo.Message{}
+			if err := json.Unmarshal(value, message); err != nil {
+				return fmt.Errorf("failed to unmarshal message %s: %w", key, err)
+			}
+
+			if message.ChannelID == channelID {
+				messages = append(messages, message)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s *BoltMessageStore) Prune(olderThan time.Time) (int, error) {
+	limit := []byte(fmt.Sprintf("%020d", snowflakeFromTime(olderThan)))
+
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltMessagesBucket)
+		cursor := bucket.Cursor()
+
+		// Deleting under the cursor skips elements, collect keys first
+		keys := [][]byte{}
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, limit) < 0; key, _ = cursor.Next() {
+			keys = append(keys, bytes.Clone(key))
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			removed++
+		}
+
+		return nil
+	})
+
+	return removed, err
+}
+
+func (s *BoltMessageStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type MemoryMessageStore struct {
+	mut      sync.RWMutex
+	messages map[string]*discordgo.Message
+	// authors maps guild and author ID to the ID of the first message
+	authors map[string]string
+}
+
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{
+		messages: map[string]*discordgo.Message{},
+		authors:  map[string]string{},
+	}
+}
+
+func (s *MemoryMessageStore) Save(message *discordgo.Message) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	copied := *message
+	s.messages[message.ID] = &copied
+
+	return nil
+}
+
+func (s *MemoryMessageStore) Get(channelID, messageID string) (*discordgo.Message, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	message, found := s.messages[messageID]
+	if !found || message.ChannelID != channelID {
+		return nil, ErrMessageNotFound
+	}
+
+	copied := *message
+	return &copied, nil
+}
+
+func (s *MemoryMessageStore) RecordAuthor(guildID, authorID, messageID string) (string, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	key := guildID + "/" + authorID
+	if firstMessageID, found := s.authors[key]; found {
+		return firstMessageID, nil
+	}
+
+	s.authors[key] = messageID
+	return messageID, nil
+}
+
+func (s *MemoryMessageStore) Range(channelID string, from, to time.Time) ([]*discordgo.Message, error) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	messages := []*discordgo.Message{}
+	for _, message := range s.messages {
+		if message.ChannelID != channelID || message.Timestamp.Before(from) || message.Timestamp.After(to) {
+			continue
+		}
+
+		copied := *message
+		messages = append(messages, &copied)
+	}
+
+	slices.SortFunc(messages, func(a, b *discordgo.Message) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return messages, nil
+}
+
+func (s *MemoryMessageStore) Prune(olderThan time.Time) (int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	removed := 0
+	for id, message := range s.messages {
+		if message.Timestamp.Before(olderThan) {
+			delete(s.messages, id)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *MemoryMessageStore) Close() error {
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const DefaultTranscriptsPath = "transcripts"
+
+// TranscriptMessage is the copy of the message recorded before it is deleted
+type TranscriptMessage struct {
+	ID           string    `json:"id"`
+	AuthorID     string    `json:"author_id"`
+	AuthorName   string    `json:"author_name"`
+	AuthorAvatar string    `json:"author_avatar,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+	Content      string    `json:"content"`
+	// Embeds is the text of link previews, it is not restored, because discord adds previews again
+	Embeds      string   `json:"embeds,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// Transcript records messages selected by the wipe job, so moderators can check what was removed
+type Transcript struct {
+	JobID       string              `json:"job_id"`
+	GuildID     string              `json:"guild_id"`
+	ChannelID   string              `json:"channel_id"`
+	ModeratorID string              `json:"moderator_id"`
+	Moderator   string              `json:"moderator"`
+	Filter      string              `json:"filter"`
+	StartedAt   time.Time           `json:"started_at"`
+	FinishedAt  time.Time           `json:"finished_at"`
+	Messages    []TranscriptMessage `json:"messages"`
+
+	mut sync.Mutex
+	// seen contains IDs of recorded messages, pages scanned again after the resume are not duplicated
+	seen map[string]bool
+	// journal receives every recorded message as the JSON line, so the transcript survives the restart
+	journal io.Writer
+}
+
+func newTranscriptMessage(m *discordgo.Message) TranscriptMessage {
+	message := TranscriptMessage{
+		ID:        m.ID,
+		Timestamp: m.Timestamp,
+		Content:   m.Content,
+		Embeds:    embedsText(m),
+	}
+	if m.Author != nil {
+		message.AuthorID = m.Author.ID
+		message.AuthorName = m.Author.Username
+		message.AuthorAvatar = m.Author.AvatarURL("")
+	}
+	for _, attachment := range m.Attachments {
+		message.Attachments = append(message.Attachments, attachment.URL)
+	}
+
+	return message
+}
+
+func NewTranscript(job *WipeJob) *Transcript {
+	transcript := &Transcript{
+		JobID:     job.ID,
+		GuildID:   job.GuildID,
+		ChannelID: job.ChannelID,
+		Filter:    job.Filter.String(),
+		StartedAt: job.StartedAt,
+		Messages:  []TranscriptMessage{},
+		seen:      map[string]bool{},
+	}
+	if job.Author != nil {
+		transcript.ModeratorID = job.Author.ID
+		transcript.Moderator = job.Author.Username
+	} else if job.Scheduled {
+		transcript.Moderator = "retention policy"
+	}
+
+	return transcript
+}
+
+// Restore adds messages recorded before the restart without writing them to the journal
+func (t *Transcript) Restore(messages []TranscriptMessage) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	for _, message := range messages {
+		if t.seen[message.ID] {
+			continue
+		}
+		t.seen[message.ID] = true
+		t.Messages = append(t.Messages, message)
+	}
+}
+
+func (t *Transcript) SetJournal(journal io.Writer) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.journal = journal
+}
+
+func (t *Transcript) Add(messages []*discordgo.Message) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	errs := []error{}
+	for _, m := range messages {
+		if t.seen[m.ID] {
+			continue
+		}
+
+		message := newTranscriptMessage(m)
+
+		t.seen[m.ID] = true
+		t.Messages = append(t.Messages, message)
+
+		if t.journal != nil {
+			if err := json.NewEncoder(t.journal).Encode(message); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *Transcript) Len() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return len(t.Messages)
+}
+
+// Finish sorts messages from the oldest one, as they are read in the channel
+func (t *Transcript) Finish(finishedAt time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.FinishedAt = finishedAt
+	slices.SortFunc(t.Messages, func(a, b TranscriptMessage) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+}
+
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(t)
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Wipe {{.JobID}} of channel {{.ChannelID}}</title>
+<style>
+body { font-family: sans-serif; background: #313338; color: #dbdee1; }
+.message { padding: 6px 0; border-bottom: 1px solid #3f4147; }
+.author { font-weight: bold; color: #f2f3f5; }
+.meta { font-size: 0.8em; color: #949ba4; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+.embed { white-space: pre-wrap; word-wrap: break-word; border-left: 4px solid #4e5058; padding-left: 8px; color: #949ba4; }
+a { color: #00a8fc; }
+</style>
+</head>
+<body>
+<h1>Wipe {{.JobID}}</h1>
+<p class="meta">
+Guild: {{.GuildID}}<br>
+Channel: {{.ChannelID}}<br>
+Moderator: {{.Moderator}} ({{.ModeratorID}})<br>
+Filters: {{.Filter}}<br>
+Started: {{.StartedAt.UTC.Format "2006-01-02 15:04:05 MST"}}<br>
+Finished: {{.FinishedAt.UTC.Format "2006-01-02 15:04:05 MST"}}<br>
+Messages: {{len .Messages}}
+</p>
+{{range .Messages}}<div class="message">
+<span class="author">{{.AuthorName}}</span> <span class="meta">{{.AuthorID}} at {{.Timestamp.UTC.Format "2006-01-02 15:04:05 MST"}}, message {{.ID}}</span>
+<div class="content">{{.Content}}</div>
+{{if .Embeds}}<div class="embed">{{.Embeds}}</div>
+{{end}}{{range .Attachments}}<div><a href="{{.}}">{{.}}</a></div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+func (t *Transcript) WriteHTML(w io.Writer) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	return transcriptTemplate.Execute(w, t)
+}
+
+// Save writes the JSON and HTML transcripts to the directory and returns them as files for the report
+func (t *Transcript) Save(dir string) ([]*discordgo.File, error) {
+	if dir == "" {
+		dir = DefaultTranscriptsPath
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create transcripts directory %s: %w", dir, err)
+	}
+
+	name := fmt.Sprintf("wipe-%s-%s-%s", t.ChannelID, t.StartedAt.UTC().Format("20060102-150405"), t.JobID)
+
+	files := []*discordgo.File{}
+	for _, format := range []struct {
+		extension   string
+		contentType string
+		write       func(w io.Writer) error
+	}{
+		{extension: "json", contentType: "application/json", write: t.WriteJSON},
+		{extension: "html", contentType: "text/html", write: t.WriteHTML},
+	} {
+		buf := &bytes.Buffer{}
+		if err := format.write(buf); err != nil {
+			return nil, fmt.Errorf("failed to render %s transcript: %w", format.extension, err)
+		}
+
+		fileName := fmt.Sprintf("%s.%s", name, format.extension)
+		if err := os.WriteFile(filepath.Join(dir, fileName), buf.Bytes(), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write transcript %s: %w", fileName, err)
+		}
+
+		files = append(files, &discordgo.File{
+			Name:        fileName,
+			ContentType: format.contentType,
+			Reader:      buf,
+		})
+	}
+
+	return files, nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultWipeJobsPath = "wipe_jobs"
+
+	WipeJobsResumeAsk  = "ask"
+	WipeJobsResumeAuto = "auto"
+
+	wipeResumeComponentPrefix  = "wipe_resume"
+	wipeResumeComponentResume  = "resume"
+	wipeResumeComponentDiscard = "discard"
+)
+
+// WipeCheckpoint is the state of the wipe job saved after every deleted page
+type WipeCheckpoint struct {
+	ID                string          `json:"id"`
+	GuildID           string          `json:"guild_id"`
+	ChannelID         string          `json:"channel_id"`
+	Author            *discordgo.User `json:"author"`
+	Filter            WipeFilter      `json:"filter"`
+	Total             int             `json:"total"`
+	Deleted           int             `json:"deleted"`
+	StartedAt         time.Time       `json:"started_at"`
+	ProgressMessageID string          `json:"progress_message_id"`
+	Cursor            WipeCursor      `json:"cursor"`
+	Scheduled         bool            `json:"scheduled"`
+}
+
+// WipeCheckpoints keeps checkpoints of running wipe jobs on the disk. Every job has
+// the checkpoint file and the journal with transcript messages appended after every page.
+type WipeCheckpoints struct {
+	dir string
+}
+
+func NewWipeCheckpoints(dir string) (*WipeCheckpoints, error) {
+	if dir == "" {
+		dir = DefaultWipeJobsPath
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create wipe jobs directory %s: %w", dir, err)
+	}
+
+	return &WipeCheckpoints{dir: dir}, nil
+}
+
+func (c *WipeCheckpoints) checkpointPath(id string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s.json", blobNameRegex.ReplaceAllString(id, "_")))
+}
+
+func (c *WipeCheckpoints) journalPath(id string) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s.transcript.jsonl", blobNameRegex.ReplaceAllString(id, "_")))
+}
+
+// Save replaces the checkpoint atomically, so it is never left half written
+func (c *WipeCheckpoints) Save(checkpoint WipeCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	path := c.checkpointPath(checkpoint.ID)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (c *WipeCheckpoints) Get(id string) (WipeCheckpoint, error) {
+	checkpoint := WipeCheckpoint{}
+
+	data, err := os.ReadFile(c.checkpointPath(id))
+	if err != nil {
+		return checkpoint, fmt.Errorf("failed to read checkpoint %s: %w", id, err)
+	}
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("failed to unmarshal checkpoint %s: %w", id, err)
+	}
+
+	return checkpoint, nil
+}
+
+// List returns checkpoints of jobs that did not finish
+func (c *WipeCheckpoints) List() ([]WipeCheckpoint, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wipe jobs directory: %w", err)
+	}
+
+	checkpoints := []WipeCheckpoint{}
+	for _, entry := range entries {
+		id, found := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !found {
+			continue
+		}
+
+		checkpoint, err := c.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, nil
+}
+
+// Remove deletes the checkpoint and the journal of the finished job
+func (c *WipeCheckpoints) Remove(id string) error {
+	errs := []error{}
+	for _, path := range []string{c.checkpointPath(id), c.journalPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// OpenJournal opens the transcript journal of the job for appending
+func (c *WipeCheckpoints) OpenJournal(id string) (*os.File, error) {
+	file, err := os.OpenFile(c.journalPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript journal: %w", err)
+	}
+
+	return file, nil
+}
+
+// ReadJournal returns messages recorded in the transcript journal. The last line
+// may be incomplete when the bot stopped during the write, it is skipped.
+func (c *WipeCheckpoints) ReadJournal(id string) ([]TranscriptMessage, error) {
+	file, err := os.Open(c.journalPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return []TranscriptMessage{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript journal: %w", err)
+	}
+	defer file.Close()
+
+	messages := []TranscriptMessage{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		message := TranscriptMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript journal: %w", err)
+	}
+
+	return messages, nil
+}
+
+// resumeWipeJobs continues jobs interrupted by the restart. Depending on the config they are
+// resumed automatically, or moderators are asked in the report channel.
+func resumeWipeJobs(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot) {
+	checkpoints, err := bot.wipeCheckpoints.List()
+	if err != nil {
+		logger.Error("failed to list wipe checkpoints", zap.Error(err))
+		return
+	}
+
+	config := bot.Config()
+	for _, checkpoint := range checkpoints {
+		job := wipeJobFromCheckpoint(checkpoint)
+
+		if config.WipeJobs.Resume == WipeJobsResumeAuto {
+			if err := startWipeJob(logger, discord, bot, job); err != nil {
+				logger.Error("failed to resume wipe job", zap.String("job", job.ID), zap.Error(err))
+				continue
+			}
+
+			logger.Sugar().Infof("Wipe job %s in channel %s resumed", job.ID, job.ChannelID)
+			continue
+		}
+
+		if _, err := discord.ChannelMessageSendComplex(config.ForGuild(job.GuildID).ReportChannel, &discordgo.MessageSend{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title: fmt.Sprintf("Wipe job %s was interrupted by the restart", job.ID),
+					Color: ReportColorCommand,
+					Fields: []*discordgo.MessageEmbedField{
+						{Name: "Channel", Value: fmt.Sprintf("<#%s>", job.ChannelID), Inline: true},
+						{Name: "Started", Value: discordTimestamp(job.StartedAt), Inline: true},
+						{Name: "Progress", Value: job.Progress()},
+						{Name: "Filters", Value: job.Filter.String()},
+					},
+				},
+			},
+			Components: wipeResumeComponents(job.ID),
+		}); err != nil {
+			logger.Error("failed to ask about interrupted wipe job", zap.String("job", job.ID), zap.Error(err))
+		}
+	}
+}
+
+func wipeResumeComponents(jobID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Resume",
+					Style:    discordgo.DangerButton,
+					CustomID: strings.Join([]string{wipeResumeComponentPrefix, wipeResumeComponentResume, jobID}, ":"),
+				},
+				discordgo.Button{
+					Label:    "Discard",
+					Style:    discordgo.SecondaryButton,
+					CustomID: strings.Join([]string{wipeResumeComponentPrefix, wipeResumeComponentDiscard, jobID}, ":"),
+				},
+			},
+		},
+	}
+}
+
+// wipeResumeComponentHandler handles Resume and Discard buttons of the interrupted wipe job.
+// Only users allowed to use the wipe command can use them.
+func wipeResumeComponentHandler(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, args []string) {
+	if len(args) != 2 {
+		respondEphemeral(logger, discord, interaction, "Unknown action.")
+		return
+	}
+	action, jobID := args[0], args[1]
+
+	user := interactionUser(interaction)
+	config := bot.Config().ForGuild(interaction.GuildID)
+	if user == nil || interaction.GuildID == "" || !isUserWhitelisted(logger, discord, bot, config.Commands.Wipe.WhitelistedRoles, interaction.GuildID, user.ID) {
+		respondEphemeral(logger, discord, interaction, "You are not allowed to use this command.")
+		return
+	}
+
+	if bot.wipeJobs.Get(jobID) != nil {
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` is already running.", jobID))
+		return
+	}
+
+	checkpoint, err := bot.wipeCheckpoints.Get(jobID)
+	if err != nil || checkpoint.GuildID != interaction.GuildID {
+		updateComponentMessage(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` is not available anymore.", jobID))
+		return
+	}
+	job := wipeJobFromCheckpoint(checkpoint)
+
+	switch action {
+	case wipeResumeComponentResume:
+		if err := startWipeJob(logger, discord, bot, job); err != nil {
+			logger.Info("Failed to resume wipe job", zap.String("job", jobID), zap.Error(err))
+			respondEphemeral(logger, discord, interaction, fmt.Sprintf("Failed to resume wipe: %s", err.Error()))
+			return
+		}
+
+		logger.Sugar().Infof("Wipe job %s resumed by %s", jobID, user.ID)
+		updateComponentMessage(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` resumed by <@%s>.", jobID, user.ID))
+	case wipeResumeComponentDiscard:
+		discardWipeJob(logger, discord, bot, job, config)
+
+		logger.Sugar().Infof("Wipe job %s discarded by %s", jobID, user.ID)
+		updateComponentMessage(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` discarded by <@%s> after deleting %d messages.", jobID, user.ID, job.Deleted()))
+	default:
+		respondEphemeral(logger, discord, interaction, "Unknown action.")
+	}
+}
+
+// discardWipeJob removes the checkpoint, the transcript of messages deleted before the restart is still reported
+func discardWipeJob(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, job *WipeJob, config ConfigGuild) {
+	messages, err := bot.wipeCheckpoints.ReadJournal(job.ID)
+	if err != nil {
+		logger.Warn("failed to read transcript journal", zap.String("job", job.ID), zap.Error(err))
+	}
+
+	if len(messages) > 0 {
+		transcript := NewTranscript(job)
+		transcript.Restore(messages)
+		transcript.Finish(time.Now())
+
+		files, err := transcript.Save(config.Commands.Wipe.TranscriptsPath)
+		if err != nil {
+			logger.Error("failed to save wipe transcript", zap.String("job", job.ID), zap.Error(err))
+		}
+
+		sendReport(logger, discord, config.ReportChannel, Report{
+			Title:     fmt.Sprintf("Wipe job %s discarded", job.ID),
+			Color:     ReportColorCommand,
+			Feature:   "wipe",
+			Author:    job.Author,
+			GuildID:   job.GuildID,
+			ChannelID: job.ChannelID,
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Messages deleted", Value: fmt.Sprintf("%d", job.Deleted()), Inline: true},
+				{Name: "Filters", Value: job.Filter.String()},
+			},
+			Files: files,
+		})
+	}
+
+	if err := bot.wipeCheckpoints.Remove(job.ID); err != nil {
+		logger.Warn("failed to remove wipe checkpoint", zap.String("job", job.ID), zap.Error(err))
+	}
+}
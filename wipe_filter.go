@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	WipeOptionUser      = "user"
+	WipeOptionCount     = "count"
+	WipeOptionSince     = "since"
+	WipeOptionContains  = "contains"
+	WipeOptionLinksOnly = "links-only"
+	WipeOptionBotsOnly  = "bots-only"
+)
+
+var linkRegex = regexp.MustCompile(`https?:/\/?\S+`)
+
+// WipeFilter selects messages deleted by the wipe command. Zero value matches all the messages.
+type WipeFilter struct {
+	UserID string
+	// Count is the max number of deleted messages, 0 means no limit
+	Count int
+	// Since limits the wipe to messages posted within the given time, 0 means no limit
+	Since     time.Duration
+	Contains  string
+	LinksOnly bool
+	BotsOnly  bool
+
+	// OlderThan and Keep are set by retention policies. Matching messages younger than OlderThan
+	// and within Keep newest ones are not deleted, 0 means no limit.
+	OlderThan time.Duration
+	Keep      int
+}
+
+func wipeFilterOptions() []*discordgo.ApplicationCommandOption {
+	minCount := float64(1)
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionUser,
+			Name:        WipeOptionUser,
+			Description: "Delete only messages of the user",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        WipeOptionCount,
+			Description: "Max number of messages to delete",
+			MinValue:    &minCount,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        WipeOptionSince,
+			Description: "Delete only messages posted within the given time, e.g. 30m, 2h, 7d",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        WipeOptionContains,
+			Description: "Delete only messages containing the text",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        WipeOptionLinksOnly,
+			Description: "Delete only messages with links",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        WipeOptionBotsOnly,
+			Description: "Delete only messages sent by bots",
+		},
+	}
+}
+
+func wipeFilterFromOptions(options []*discordgo.ApplicationCommandInteractionDataOption) (WipeFilter, error) {
+	filter := WipeFilter{}
+
+	for _, option := range options {
+		switch option.Name {
+		case WipeOptionUser:
+			filter.UserID = fmt.Sprintf("%v", option.Value)
+		case WipeOptionCount:
+			filter.Count = int(option.IntValue())
+		case WipeOptionSince:
+			since, err := parseWipeDuration(option.StringValue())
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s value: %w", WipeOptionSince, err)
+			}
+			filter.Since = since
+		case WipeOptionContains:
+			filter.Contains = option.StringValue()
+		case WipeOptionLinksOnly:
+			filter.LinksOnly = option.BoolValue()
+		case WipeOptionBotsOnly:
+			filter.BotsOnly = option.BoolValue()
+		}
+	}
+
+	return filter, nil
+}
+
+// parseWipeDuration parses go durations and also days, e.g. 7d
+func parseWipeDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid number of days: %s", value)
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %s", value)
+	}
+
+	return duration, nil
+}
+
+// Cutoff returns the time of the oldest message that can be deleted, zero time means no limit
+func (f WipeFilter) Cutoff(startedAt time.Time) time.Time {
+	if f.Since <= 0 {
+		return time.Time{}
+	}
+
+	return startedAt.Add(-f.Since)
+}
+
+// Retain reports whether the message is kept by the retention policy. Messages must be passed
+// from the newest one, kept is the number of newer messages already retained.
+func (f WipeFilter) Retain(message *discordgo.Message, kept int, startedAt time.Time) bool {
+	if f.OlderThan <= 0 && f.Keep <= 0 {
+		return false
+	}
+
+	if f.Keep > 0 && kept >= f.Keep {
+		return false
+	}
+
+	if f.OlderThan > 0 && message.Timestamp.Before(startedAt.Add(-f.OlderThan)) {
+		return false
+	}
+
+	return true
+}
+
+func (f WipeFilter) Match(message *discordgo.Message) bool {
+	if f.UserID != "" && (message.Author == nil || message.Author.ID != f.UserID) {
+		return false
+	}
+
+	if f.BotsOnly && (message.Author == nil || !message.Author.Bot) {
+		return false
+	}
+
+	text := messageText(message)
+	if f.Contains != "" &&
+		!strings.Contains(strings.ToLower(text), strings.ToLower(f.Contains)) &&
+		!strings.Contains(strings.ToLower(normalizeText(text)), strings.ToLower(normalizeText(f.Contains))) {
+		return false
+	}
+
+	if f.LinksOnly && !linkRegex.MatchString(text) && !isDiscordInvitation(text) {
+		return false
+	}
+
+	return true
+}
+
+func (f WipeFilter) String() string {
+	filters := []string{}
+
+	if f.UserID != "" {
+		filters = append(filters, fmt.Sprintf("user: <@%s>", f.UserID))
+	}
+	if f.Count > 0 {
+		filters = append(filters, fmt.Sprintf("count: %d", f.Count))
+	}
+	if f.Since > 0 {
+		filters = append(filters, fmt.Sprintf("since: %s", f.Since))
+	}
+	if f.Contains != "" {
+		filters = append(filters, fmt.Sprintf("contains: %q", f.Contains))
+	}
+	if f.LinksOnly {
+		filters = append(filters, "links only")
+	}
+	if f.BotsOnly {
+		filters = append(filters, "bots only")
+	}
+	if f.OlderThan > 0 {
+		filters = append(filters, fmt.Sprintf("older than: %s", f.OlderThan))
+	}
+	if f.Keep > 0 {
+		filters = append(filters, fmt.Sprintf("keep newest: %d", f.Keep))
+	}
+
+	if len(filters) < 1 {
+		return "none, all messages"
+	}
+
+	return strings.Join(filters, "\n")
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const WipeProgressInterval = 5 * time.Second
+
+// WipeJob deletes messages in the background, it can be canceled with the wipe cancel command
+type WipeJob struct {
+	ID        string
+	GuildID   string
+	ChannelID string
+	Author    *discordgo.User
+	Filter    WipeFilter
+	// Total is the number of messages counted in the preview
+	Total     int
+	StartedAt time.Time
+
+	ProgressMessageID string
+	Cursor            WipeCursor
+	// Scheduled jobs are started by the retention policy
+	Scheduled bool
+
+	deleted atomic.Int64
+	cancel  context.CancelFunc
+}
+
+func wipeJobFromCheckpoint(checkpoint WipeCheckpoint) *WipeJob {
+	job := &WipeJob{
+		ID:                checkpoint.ID,
+		GuildID:           checkpoint.GuildID,
+		ChannelID:         checkpoint.ChannelID,
+		Author:            checkpoint.Author,
+		Filter:            checkpoint.Filter,
+		Total:             checkpoint.Total,
+		StartedAt:         checkpoint.StartedAt,
+		ProgressMessageID: checkpoint.ProgressMessageID,
+		Cursor:            checkpoint.Cursor,
+		Scheduled:         checkpoint.Scheduled,
+	}
+	job.deleted.Store(int64(checkpoint.Deleted))
+
+	return job
+}
+
+// Checkpoint must be called from the job goroutine, the cursor is not synchronized
+func (j *WipeJob) Checkpoint() WipeCheckpoint {
+	return WipeCheckpoint{
+		ID:                j.ID,
+		GuildID:           j.GuildID,
+		ChannelID:         j.ChannelID,
+		Author:            j.Author,
+		Filter:            j.Filter,
+		Total:             j.Total,
+		Deleted:           j.Deleted(),
+		StartedAt:         j.StartedAt,
+		ProgressMessageID: j.ProgressMessageID,
+		Cursor:            j.Cursor,
+		Scheduled:         j.Scheduled,
+	}
+}
+
+func (j *WipeJob) Deleted() int {
+	return int(j.deleted.Load())
+}
+
+// ETA estimates the remaining time from the current delete rate
+func (j *WipeJob) ETA() time.Duration {
+	deleted := j.Deleted()
+	remaining := j.Total - deleted
+	if deleted < 1 || remaining < 1 {
+		return 0
+	}
+
+	elapsed := time.Since(j.StartedAt)
+	return time.Duration(float64(elapsed) / float64(deleted) * float64(remaining)).Round(time.Second)
+}
+
+func (j *WipeJob) Progress() string {
+	eta := "unknown"
+	if estimated := j.ETA(); estimated > 0 {
+		eta = estimated.String()
+	}
+
+	// Scheduled jobs do not count messages before they start
+	if j.Total < 1 {
+		return fmt.Sprintf("%d messages deleted", j.Deleted())
+	}
+
+	return fmt.Sprintf("%d/%d messages deleted, ETA: %s", j.Deleted(), j.Total, eta)
+}
+
+// WipeJobs keeps running jobs, only one job can run in the channel at once
+type WipeJobs struct {
+	mut      sync.RWMutex
+	jobs     map[string]*WipeJob
+	channels map[string]string
+}
+
+func NewWipeJobs() *WipeJobs {
+	return &WipeJobs{
+		jobs:     map[string]*WipeJob{},
+		channels: map[string]string{},
+	}
+}
+
+// Start registers the job, it fails when another job is running in the same channel
+func (w *WipeJobs) Start(job *WipeJob) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if jobID, found := w.channels[job.ChannelID]; found {
+		return fmt.Errorf("wipe job %s is already running in this channel", jobID)
+	}
+
+	w.jobs[job.ID] = job
+	w.channels[job.ChannelID] = job.ID
+
+	return nil
+}
+
+func (w *WipeJobs) Finish(job *WipeJob) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	delete(w.jobs, job.ID)
+	delete(w.channels, job.ChannelID)
+}
+
+func (w *WipeJobs) Get(id string) *WipeJob {
+	w.mut.RLock()
+	defer w.mut.RUnlock()
+
+	return w.jobs[id]
+}
+
+func (w *WipeJobs) ChannelBusy(channelID string) bool {
+	w.mut.RLock()
+	defer w.mut.RUnlock()
+
+	_, found := w.channels[channelID]
+	return found
+}
+
+// List returns running jobs of the guild, the oldest first
+func (w *WipeJobs) List(guildID string) []*WipeJob {
+	w.mut.RLock()
+	defer w.mut.RUnlock()
+
+	jobs := []*WipeJob{}
+	for _, job := range w.jobs {
+		if job.GuildID == guildID {
+			jobs = append(jobs, job)
+		}
+	}
+
+	slices.SortFunc(jobs, func(a, b *WipeJob) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+
+	return jobs
+}
+
+func newWipeID(length int) (string, error) {
+	idBytes := make([]byte, length)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+
+	return hex.EncodeToString(idBytes), nil
+}
+
+// startWipeJob registers the job and runs it in the background. Jobs restored from
+// the checkpoint keep their ID and continue from the saved cursor.
+func startWipeJob(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, job *WipeJob) error {
+	if job.ID == "" {
+		id, err := newWipeID(3)
+		if err != nil {
+			return err
+		}
+		job.ID = id
+	}
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	if job.Cursor.StartedAt.IsZero() {
+		job.Cursor.StartedAt = job.StartedAt
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+
+	if err := bot.wipeJobs.Start(job); err != nil {
+		cancel()
+		return err
+	}
+
+	go runWipeJob(ctx, logger.With(zap.String("job", job.ID)), discord, bot, job)
+
+	return nil
+}
+
+func runWipeJob(ctx context.Context, logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, job *WipeJob) {
+	defer bot.wipeJobs.Finish(job)
+	defer job.cancel()
+
+	// Scheduled jobs do not post the progress to the channel, they are reported only to the report channel
+	if !job.Scheduled {
+		if err := sendWipeProgressMessage(discord, job); err != nil {
+			logger.Error("failed to send wipe progress message", zap.Error(err))
+			return
+		}
+	}
+
+	if err := bot.wipeCheckpoints.Save(job.Checkpoint()); err != nil {
+		logger.Warn("failed to save wipe checkpoint", zap.Error(err))
+	}
+	defer func() {
+		if err := bot.wipeCheckpoints.Remove(job.ID); err != nil {
+			logger.Warn("failed to remove wipe checkpoint", zap.Error(err))
+		}
+	}()
+
+	transcript := NewTranscript(job)
+	if messages, err := bot.wipeCheckpoints.ReadJournal(job.ID); err != nil {
+		logger.Warn("failed to read transcript journal", zap.Error(err))
+	} else {
+		transcript.Restore(messages)
+	}
+	if journal, err := bot.wipeCheckpoints.OpenJournal(job.ID); err != nil {
+		logger.Warn("failed to open transcript journal, transcript will not survive the restart", zap.Error(err))
+	} else {
+		defer journal.Close()
+		transcript.SetJournal(journal)
+	}
+
+	progressDone := make(chan struct{})
+	go func() {
+		if job.ProgressMessageID == "" {
+			return
+		}
+
+		t := time.NewTicker(WipeProgressInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if _, err := discord.ChannelMessageEdit(job.ChannelID, job.ProgressMessageID, fmt.Sprintf("Wipe job `%s` in progress: %s", job.ID, job.Progress())); err != nil {
+					logger.Warn("failed to update wipe progress message", zap.Error(err))
+				}
+			case <-progressDone:
+				return
+			}
+		}
+	}()
+
+	_, err := wipeMessages(ctx, logger, discord, bot, job.ChannelID, &job.Cursor, job.Filter, transcript, func(deleted int) {
+		job.deleted.Add(int64(deleted))
+
+		if err := bot.wipeCheckpoints.Save(job.Checkpoint()); err != nil {
+			logger.Warn("failed to save wipe checkpoint", zap.Error(err))
+		}
+	})
+	close(progressDone)
+	transcript.Finish(time.Now())
+
+	status := "finished"
+	switch {
+	case errors.Is(err, context.Canceled):
+		status = "canceled"
+	case err != nil:
+		status = fmt.Sprintf("failed: %s", err.Error())
+		logger.Error("Wipe job failed", zap.Error(err))
+	}
+
+	if job.ProgressMessageID != "" {
+		if _, err := discord.ChannelMessageEdit(job.ChannelID, job.ProgressMessageID, fmt.Sprintf("Wipe job `%s` %s: %d messages deleted", job.ID, status, job.Deleted())); err != nil {
+			logger.Warn("failed to update wipe progress message", zap.Error(err))
+		}
+	}
+
+	title := fmt.Sprintf("Wipe job %s %s", job.ID, status)
+	if job.Scheduled {
+		title = fmt.Sprintf("Retention wipe job %s %s", job.ID, status)
+	}
+
+	guildConfig := bot.Config().ForGuild(job.GuildID)
+	report := Report{
+		Title:     title,
+		Color:     ReportColorCommand,
+		Feature:   "wipe",
+		Author:    job.Author,
+		GuildID:   job.GuildID,
+		ChannelID: job.ChannelID,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Messages deleted", Value: fmt.Sprintf("%d", job.Deleted()), Inline: true},
+			{Name: "Duration", Value: time.Since(job.StartedAt).Round(time.Second).String(), Inline: true},
+			{Name: "Filters", Value: job.Filter.String()},
+		},
+	}
+
+	if transcript.Len() > 0 {
+		files, err := transcript.Save(guildConfig.Commands.Wipe.TranscriptsPath)
+		if err != nil {
+			logger.Error("failed to save wipe transcript", zap.Error(err))
+			report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: "Transcript", Value: fmt.Sprintf("failed to save: %s", err.Error())})
+		}
+		report.Files = files
+	}
+
+	sendReport(logger, discord, guildConfig.ReportChannel, report)
+}
+
+// sendWipeProgressMessage posts the progress message before the wipe starts, so only messages older than
+// it are deleted. The resumed job edits its old progress message, the cursor already points before it.
+func sendWipeProgressMessage(discord *discordgo.Session, job *WipeJob) error {
+	var progressMessage *discordgo.Message
+	if job.ProgressMessageID != "" {
+		progressMessage, _ = discord.ChannelMessageEdit(job.ChannelID, job.ProgressMessageID, fmt.Sprintf("Wipe job `%s` resumed: %s", job.ID, job.Progress()))
+	}
+	if progressMessage == nil {
+		var err error
+		progressMessage, err = discord.ChannelMessageSend(job.ChannelID, fmt.Sprintf("Wipe job `%s` started: %s", job.ID, job.Progress()))
+		if err != nil {
+			return err
+		}
+	}
+
+	job.ProgressMessageID = progressMessage.ID
+	if job.Cursor.Before == "" {
+		job.Cursor.Before = progressMessage.ID
+	}
+
+	return nil
+}
+
+func commandWipeStatus(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot) {
+	jobs := bot.wipeJobs.List(interaction.GuildID)
+	if len(jobs) < 1 {
+		respondEphemeral(logger, discord, interaction, "No wipe jobs are running.")
+		return
+	}
+
+	lines := []string{}
+	for _, job := range jobs {
+		author := "unknown"
+		if job.Author != nil {
+			author = fmt.Sprintf("<@%s>", job.Author.ID)
+		}
+
+		lines = append(lines, fmt.Sprintf("`%s` in <#%s> by %s, started %s: %s", job.ID, job.ChannelID, author, discordTimestamp(job.StartedAt), job.Progress()))
+	}
+
+	respondEphemeral(logger, discord, interaction, truncateText(strings.Join(lines, "\n"), 2000))
+}
+
+func commandWipeCancel(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, jobID string) {
+	job := bot.wipeJobs.Get(strings.TrimSpace(jobID))
+	if job == nil || job.GuildID != interaction.GuildID {
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` is not running.", jobID))
+		return
+	}
+
+	job.cancel()
+	logger.Sugar().Infof("Wipe job %s canceled by %s", job.ID, interactionUser(interaction).ID)
+
+	respondEphemeral(logger, discord, interaction, fmt.Sprintf("Wipe job `%s` canceled after deleting %d messages.", job.ID, job.Deleted()))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// commandWipeNuke asks for the confirmation to clone the channel and delete the original one.
+// It is much faster than the wipe for channels with a lot of messages.
+func commandWipeNuke(
+	logger *zap.Logger,
+	interaction *discordgo.InteractionCreate,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+) {
+	if bot.wipeJobs.ChannelBusy(interaction.ChannelID) {
+		respondEphemeral(logger, discord, interaction, "Wipe job is still running in this channel, wait until it is finished or cancel it before triggering next command.")
+		return
+	}
+
+	channel, err := discord.Channel(interaction.ChannelID)
+	if err != nil {
+		logger.Error("Failed to get channel", zap.Error(err))
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("Failed to get the channel: %s", err.Error()))
+		return
+	}
+
+	token, err := bot.pendingWipes.Add(&PendingWipe{
+		UserID:      interactionUser(interaction).ID,
+		GuildID:     interaction.GuildID,
+		ChannelID:   interaction.ChannelID,
+		Nuke:        true,
+		interaction: interaction,
+	}, func(pending *PendingWipe) {
+		logger.Sugar().Infof("Nuke confirmation for channel %s expired", pending.ChannelID)
+
+		content := "Nuke confirmation expired, nothing was deleted."
+		components := []discordgo.MessageComponent{}
+		if _, err := discord.InteractionResponseEdit(pending.interaction.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &components,
+		}); err != nil {
+			logger.Error("failed to edit interaction response", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logger.Error("Failed to create nuke confirmation", zap.Error(err))
+		respondEphemeral(logger, discord, interaction, fmt.Sprintf("Failed to create nuke confirmation: %s", err.Error()))
+		return
+	}
+
+	if err := discord.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("Confirm the nuke within %s.", WipeConfirmationTimeout),
+			Embeds:     []*discordgo.MessageEmbed{nukeEmbed(channel)},
+			Components: wipeConfirmationComponents(token),
+			Flags:      discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
+
+func nukeEmbed(channel *discordgo.Channel) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       "Nuke preview",
+		Description: "The channel will be cloned with the same settings and permissions. The original channel will be deleted with all its messages.",
+		Color:       ReportColorCommand,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Channel", Value: fmt.Sprintf("<#%s> (%s)", channel.ID, channel.Name), Inline: true},
+			{Name: "Category", Value: nonEmpty(channel.ParentID), Inline: true},
+			{Name: "Permission overwrites", Value: fmt.Sprintf("%d", len(channel.PermissionOverwrites)), Inline: true},
+		},
+	}
+}
+
+// confirmNuke clones the channel, moves config references to the clone and deletes the original channel
+func confirmNuke(
+	logger *zap.Logger,
+	interaction *discordgo.InteractionCreate,
+	discord *discordgo.Session,
+	bot *DiscordBot,
+	pending *PendingWipe,
+) {
+	// The interaction message disappears with the channel, so it is updated before the nuke
+	updateComponentMessage(logger, discord, interaction, "Nuking the channel...")
+
+	report := Report{
+		Title:     "Channel nuked",
+		Color:     ReportColorCommand,
+		Feature:   "wipe",
+		Author:    interactionUser(interaction),
+		GuildID:   pending.GuildID,
+		ChannelID: pending.ChannelID,
+	}
+
+	oldChannel, newChannel, err := nukeChannel(logger, discord, bot, pending.ChannelID)
+	if err != nil {
+		logger.Error("Nuke failed", zap.Error(err))
+		report.Title = "Channel nuke failed"
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: "Error", Value: err.Error()})
+	}
+	if oldChannel != nil {
+		report.Fields = append(report.Fields, &discordgo.MessageEmbedField{Name: "Old channel", Value: fmt.Sprintf("%s (%s)", oldChannel.Name, oldChannel.ID), Inline: true})
+	}
+	if newChannel != nil {
+		report.ChannelID = newChannel.ID
+		report.Fields = append(report.Fields,
+			&discordgo.MessageEmbedField{Name: "New channel", Value: fmt.Sprintf("<#%s> (%s)", newChannel.ID, newChannel.ID), Inline: true},
+			&discordgo.MessageEmbedField{Name: "Config", Value: fmt.Sprintf("References to %s were replaced with %s in the running config. Update the config file, otherwise the old ID is restored on the next reload.", pending.ChannelID, newChannel.ID)},
+		)
+	}
+
+	sendReport(logger, discord, bot.Config().ForGuild(pending.GuildID).ReportChannel, report)
+}
+
+// nukeChannel creates the clone of the channel, replaces the channel ID in the running config and
+// deletes the original channel. The clone is returned also when the original cannot be deleted.
+func nukeChannel(logger *zap.Logger, discord *discordgo.Session, bot *DiscordBot, channelID string) (*discordgo.Channel, *discordgo.Channel, error) {
+	channel, err := discord.Channel(channelID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get channel %s: %w", channelID, err)
+	}
+
+	clone, err := discord.GuildChannelCreateComplex(channel.GuildID, discordgo.GuildChannelCreateData{
+		Name:                 channel.Name,
+		Type:                 channel.Type,
+		Topic:                channel.Topic,
+		Bitrate:              channel.Bitrate,
+		UserLimit:            channel.UserLimit,
+		RateLimitPerUser:     channel.RateLimitPerUser,
+		Position:             channel.Position,
+		PermissionOverwrites: channel.PermissionOverwrites,
+		ParentID:             channel.ParentID,
+		NSFW:                 channel.NSFW,
+	})
+	if err != nil {
+		return channel, nil, fmt.Errorf("failed to clone channel: %w", err)
+	}
+	logger.Sugar().Infof("Channel %s(%s) cloned to %s", channel.Name, channel.ID, clone.ID)
+
+	if err := bot.ReplaceChannel(channel.ID, clone.ID); err != nil {
+		return channel, clone, fmt.Errorf("failed to replace channel in the config: %w", err)
+	}
+	if err := discord.State.ChannelAdd(clone); err != nil {
+		logger.Warn("failed to add cloned channel to the state", zap.Error(err))
+	}
+
+	if _, err := discord.ChannelDelete(channel.ID); err != nil {
+		return channel, clone, fmt.Errorf("failed to delete the original channel: %w", err)
+	}
+	logger.Sugar().Infof("Channel %s(%s) deleted", channel.Name, channel.ID)
+
+	return channel, clone, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+const (
+	WipeOptionDryRun = "dry-run"
+
+	WipeConfirmationTimeout = 2 * time.Minute
+
+	wipeComponentPrefix  = "wipe"
+	wipeComponentConfirm = "confirm"
+	wipeComponentCancel  = "cancel"
+
+	wipePreviewTopAuthors = 5
+)
+
+// WipePreview summarizes messages that match the wipe filter
+type WipePreview struct {
+	Count  int
+	Oldest *discordgo.Message
+	Newest *discordgo.Message
+	// Authors maps author ID to the number of the matching messages
+	Authors map[string]int
+}
+
+func wipeDryRunOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        WipeOptionDryRun,
+		Description: "Only show what would be deleted",
+	}
+}
+
+func wipeDryRunFromOptions(options []*discordgo.ApplicationCommandInteractionDataOption) bool {
+	for _, option := range options {
+		if option.Name == WipeOptionDryRun {
+			return option.BoolValue()
+		}
+	}
+
+	return false
+}
+
+func previewWipe(ctx context.Context, logger *zap.Logger, discord *discordgo.Session, channelID string, filter WipeFilter) (WipePreview, error) {
+	preview := WipePreview{Authors: map[string]int{}}
+
+	err := scanWipeMessages(ctx, logger, discord, channelID, &WipeCursor{StartedAt: time.Now()}, filter, func(messages []*discordgo.Message) error {
+		for _, m := range messages {
+			preview.Count++
+
+			// Messages are scanned from the newest one
+			if preview.Newest == nil {
+				preview.Newest = m
+			}
+			preview.Oldest = m
+
+			if m.Author != nil {
+				preview.Authors[m.Author.ID]++
+			}
+		}
+
+		return nil
+	})
+
+	return preview, err
+}
+
+func (p WipePreview) Embed(channelID string, filter WipeFilter, dryRun bool) *discordgo.MessageEmbed {
+	title := "Wipe preview"
+	if dryRun {
+		title = "Wipe dry run, nothing was deleted"
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Channel", Value: fmt.Sprintf("<#%s>", channelID), Inline: true},
+		{Name: "Messages to delete", Value: fmt.Sprintf("%d", p.Count), Inline: true},
+	}
+	if p.Newest != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Newest", Value: discordTimestamp(p.Newest.Timestamp), Inline: true})
+	}
+	if p.Oldest != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Oldest", Value: discordTimestamp(p.Oldest.Timestamp), Inline: true})
+	}
+
+	authors := make([]string, 0, len(p.Authors))
+	for authorID := range p.Authors {
+		authors = append(authors, authorID)
+	}
+	slices.SortFunc(authors, func(a, b string) int {
+		return p.Authors[b] - p.Authors[a]
+	})
+
+	topAuthors := []string{}
+	for _, authorID := range authors[:min(len(authors), wipePreviewTopAuthors)] {
+		topAuthors = append(topAuthors, fmt.Sprintf("<@%s>: %d", authorID, p.Authors[authorID]))
+	}
+	if len(authors) > wipePreviewTopAuthors {
+		topAuthors = append(topAuthors, fmt.Sprintf("and %d more", len(authors)-wipePreviewTopAuthors))
+	}
+
+	fields = append(fields,
+		&discordgo.MessageEmbedField{Name: "Top authors", Value: nonEmpty(strings.Join(topAuthors, "\n"))},
+		&discordgo.MessageEmbedField{Name: "Filters", Value: filter.String()},
+	)
+
+	return &discordgo.MessageEmbed{
+		Title:  title,
+		Color:  ReportColorCommand,
+		Fields: fields,
+	}
+}
+
+// PendingWipe waits for the confirmation of the moderator who triggered the wipe
+type PendingWipe struct {
+	UserID    string
+	GuildID   string
+	ChannelID string
+	Filter    WipeFilter
+	Preview   WipePreview
+	// Nuke clones the channel and deletes the original one instead of deleting messages
+	Nuke bool
+
+	interaction *discordgo.InteractionCreate
+	timer       *time.Timer
+}
+
+type PendingWipes struct {
+	mut     sync.Mutex
+	pending map[string]*PendingWipe
+}
+
+func NewPendingWipes() *PendingWipes {
+	return &PendingWipes{
+		pending: map[string]*PendingWipe{},
+	}
+}
+
+// Add stores the pending wipe and returns its token. When it is not taken
+// within WipeConfirmationTimeout, onExpire is called.
+func (p *PendingWipes) Add(pending *PendingWipe, onExpire func(pending *PendingWipe)) (string, error) {
+	token, err := newWipeID(8)
+	if err != nil {
+		return "", err
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.pending[token] = pending
+	pending.timer = time.AfterFunc(WipeConfirmationTimeout, func() {
+		if expired := p.Take(token); expired != nil {
+			onExpire(expired)
+		}
+	})
+
+	return token, nil
+}
+
+// Take removes the pending wipe, so it can be confirmed or canceled only once
+func (p *PendingWipes) Take(token string) *PendingWipe {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	pending, found := p.pending[token]
+	if !found {
+		return nil
+	}
+
+	delete(p.pending, token)
+	pending.timer.Stop()
+
+	return pending
+}
+
+// Get returns the pending wipe without removing it
+func (p *PendingWipes) Get(token string) *PendingWipe {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	return p.pending[token]
+}
+
+func wipeConfirmationComponents(token string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Confirm",
+					Style:    discordgo.DangerButton,
+					CustomID: strings.Join([]string{wipeComponentPrefix, wipeComponentConfirm, token}, ":"),
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: strings.Join([]string{wipeComponentPrefix, wipeComponentCancel, token}, ":"),
+				},
+			},
+		},
+	}
+}
+
+// wipeComponentHandler handles Confirm and Cancel buttons of the wipe preview
+func wipeComponentHandler(logger *zap.Logger, interaction *discordgo.InteractionCreate, discord *discordgo.Session, bot *DiscordBot, args []string) {
+	if len(args) != 2 {
+		respondEphemeral(logger, discord, interaction, "Unknown action.")
+		return
+	}
+	action, token := args[0], args[1]
+
+	// Check the user before taking the pending wipe, so others cannot cancel it
+	pending := bot.pendingWipes.Get(token)
+	if pending == nil {
+		updateComponentMessage(logger, discord, interaction, "Wipe confirmation expired, nothing was deleted.")
+		return
+	}
+
+	if user := interactionUser(interaction); user == nil || user.ID != pending.UserID {
+		respondEphemeral(logger, discord, interaction, "Only the moderator who triggered the wipe can confirm it.")
+		return
+	}
+
+	if pending = bot.pendingWipes.Take(token); pending == nil {
+		updateComponentMessage(logger, discord, interaction, "Wipe confirmation expired, nothing was deleted.")
+		return
+	}
+
+	switch action {
+	case wipeComponentConfirm:
+		confirmWipe(logger, interaction, discord, bot, pending)
+	case wipeComponentCancel:
+		logger.Sugar().Infof("Wipe of channel %s canceled", pending.ChannelID)
+		updateComponentMessage(logger, discord, interaction, "Wipe canceled, nothing was deleted.")
+	default:
+		respondEphemeral(logger, discord, interaction, "Unknown action.")
+	}
+}